@@ -0,0 +1,309 @@
+/*
+Password Vault
+In the first lesson we encrypted passwords with AES-CTR, but we cheated in two ways:
+
+1. Every password was encrypted with the same constant IV "1234567812345678".
+2. Nothing protected the ciphertext from being modified.
+
+Reusing an IV with CTR mode means reusing the keystream. XOR two ciphertexts that share an IV and
+the keystream cancels out, leaving plaintext1 XOR plaintext2. A fresh random IV per entry fixes that.
+
+CTR mode is also malleable: flipping a bit in the ciphertext flips the same bit in the plaintext,
+and decryption happily returns garbage (or worse, an attacker-chosen value). To catch that we use
+Encrypt-then-MAC:
+
+ciphertext = AES-CTR(encKey, iv, plaintext)
+tag        = HMAC-SHA256(macKey, version || len(name) || name || iv || ciphertext)
+stored     = hex(version || iv || ciphertext || tag)
+
+On decryption the tag is checked BEFORE anything is decrypted. A tampered or truncated entry
+returns an error instead of garbage.
+
+The entry name is part of the MAC input even though it isn't stored in the ciphertext. Without it,
+an attacker with write access to the vault file could copy the "bank" ciphertext over the "github"
+entry, and it would still verify. The length goes in front of the name so that two different
+(name, ciphertext) pairs can never produce the same MAC input. The version byte is covered too, so
+a future format can't be confused with this one.
+
+encKey and macKey are two separate keys derived from the master key, so the same key is never
+used for two different jobs.
+
+Usage
+export PASSLY_MASTER_KEY=kjhgfdsaqwertyuioplkjhgfdsaqwert
+
+go run . add github hunter2
+go run . get github
+go run . list
+go run . delete github
+
+Use -file to pick a vault other than passly.vault. Running with no subcommand runs the demo.
+*/
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+)
+
+const (
+	formatVersion = 1
+	ivSize        = aes.BlockSize
+	tagSize       = sha256.Size
+)
+
+var (
+	errTooShort   = errors.New("ciphertext is too short")
+	errVersion    = errors.New("unknown ciphertext version")
+	errAuthFailed = errors.New("message authentication failed")
+)
+
+// deriveKeys splits the master key into an encryption key and a MAC key
+func deriveKeys(masterKey string) (encKey, macKey []byte) {
+	encKey = hmacSum([]byte(masterKey), []byte("passly encryption key"))
+	macKey = hmacSum([]byte(masterKey), []byte("passly mac key"))
+	return encKey, macKey
+}
+
+func hmacSum(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// macInput is everything the tag covers besides the ciphertext itself
+func macInput(name string, iv []byte) []byte {
+	header := []byte{formatVersion}
+	header = binary.BigEndian.AppendUint32(header, uint32(len(name)))
+	header = append(header, name...)
+	return append(header, iv...)
+}
+
+// encrypt encrypts the password stored under name. The name is bound into the tag, so the
+// ciphertext only decrypts as that entry.
+func encrypt(plainText, name, key string) (string, error) {
+	encKey, macKey := deriveKeys(key)
+	blockCipher, err := aes.NewCipher(encKey)
+	if err != nil {
+		return "", err
+	}
+
+	iv := make([]byte, ivSize)
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+
+	bytes := []byte(plainText)
+	stream := cipher.NewCTR(blockCipher, iv)
+	stream.XORKeyStream(bytes, bytes)
+
+	tag := hmacSum(macKey, macInput(name, iv), bytes)
+
+	out := append([]byte{formatVersion}, iv...)
+	out = append(out, bytes...)
+	out = append(out, tag...)
+	return fmt.Sprintf("%x", out), nil
+}
+
+func decrypt(cipherText, name, key string) (string, error) {
+	raw, err := hex.DecodeString(cipherText)
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < 1+ivSize+tagSize {
+		return "", errTooShort
+	}
+	if raw[0] != formatVersion {
+		return "", errVersion
+	}
+
+	encKey, macKey := deriveKeys(key)
+	iv := raw[1 : 1+ivSize]
+	bytes := raw[1+ivSize : len(raw)-tagSize]
+	tag := raw[len(raw)-tagSize:]
+
+	// always check the tag before decrypting
+	if !hmac.Equal(tag, hmacSum(macKey, macInput(name, iv), bytes)) {
+		return "", errAuthFailed
+	}
+
+	blockCipher, err := aes.NewCipher(encKey)
+	if err != nil {
+		return "", err
+	}
+	stream := cipher.NewCTR(blockCipher, iv)
+	stream.XORKeyStream(bytes, bytes)
+	return string(bytes), nil
+}
+
+// vault maps an entry name to its hex encoded ciphertext
+type vault map[string]string
+
+func loadVault(path string) (vault, error) {
+	v := vault{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("reading vault %s: %w", path, err)
+	}
+	return v, nil
+}
+
+func (v vault) save(path string) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write to a temp file first so a crash can't leave a half written vault
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (v vault) add(name, password, masterKey string) error {
+	encrypted, err := encrypt(password, name, masterKey)
+	if err != nil {
+		return err
+	}
+	v[name] = encrypted
+	return nil
+}
+
+func (v vault) get(name, masterKey string) (string, error) {
+	encrypted, ok := v[name]
+	if !ok {
+		return "", fmt.Errorf("no entry named %q", name)
+	}
+	password, err := decrypt(encrypted, name, masterKey)
+	if err != nil {
+		return "", fmt.Errorf("entry %q: %w", name, err)
+	}
+	return password, nil
+}
+
+func (v vault) list() []string {
+	names := []string{}
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (v vault) delete(name string) error {
+	if _, ok := v[name]; !ok {
+		return fmt.Errorf("no entry named %q", name)
+	}
+	delete(v, name)
+	return nil
+}
+
+func run(path, masterKey string, args []string) error {
+	v, err := loadVault(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case args[0] == "add" && len(args) == 3:
+		if err := v.add(args[1], args[2], masterKey); err != nil {
+			return err
+		}
+		return v.save(path)
+	case args[0] == "get" && len(args) == 2:
+		password, err := v.get(args[1], masterKey)
+		if err != nil {
+			return err
+		}
+		fmt.Println(password)
+		return nil
+	case args[0] == "list" && len(args) == 1:
+		for _, name := range v.list() {
+			fmt.Println(name)
+		}
+		return nil
+	case args[0] == "delete" && len(args) == 2:
+		if err := v.delete(args[1]); err != nil {
+			return err
+		}
+		return v.save(path)
+	}
+	return fmt.Errorf("usage: vault [-file path] add NAME PASSWORD | get NAME | list | delete NAME")
+}
+
+func debugEncryptDecrypt(masterKey, password string) {
+	encryptedPassword, err := encrypt(password, "github", masterKey)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	fmt.Printf("Encrypted password: %v\n", encryptedPassword)
+	decryptedPassword, err := decrypt(encryptedPassword, "github", masterKey)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	fmt.Printf("Decrypted password: %v\n", decryptedPassword)
+
+	// flip one bit of the ciphertext
+	raw, _ := hex.DecodeString(encryptedPassword)
+	raw[1+ivSize] ^= 0x01
+	_, err = decrypt(fmt.Sprintf("%x", raw), "github", masterKey)
+	fmt.Printf("Decrypting tampered entry: %v\n", err)
+
+	// chop off the end of the tag
+	_, err = decrypt(encryptedPassword[:len(encryptedPassword)-8], "github", masterKey)
+	fmt.Printf("Decrypting truncated entry: %v\n", err)
+
+	// move the entry to another name, as if the vault file had been edited
+	_, err = decrypt(encryptedPassword, "bank", masterKey)
+	fmt.Printf("Decrypting entry swapped to another name: %v\n", err)
+}
+
+func test(masterKey, password string) {
+	debugEncryptDecrypt(masterKey, password)
+	fmt.Println("========")
+}
+
+func main() {
+	file := flag.String("file", "passly.vault", "path to the vault file")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		const masterKey = "kjhgfdsaqwertyuioplkjhgfdsaqwert"
+		test(masterKey, "k33pThisPasswordSafe")
+		test(masterKey, "12345")
+		test(masterKey, "thePasswordOnMyLuggage")
+		test(masterKey, "pizza_the_HUt")
+		return
+	}
+
+	masterKey := os.Getenv("PASSLY_MASTER_KEY")
+	if masterKey == "" {
+		log.Fatal("PASSLY_MASTER_KEY is not set")
+	}
+	if err := run(*file, masterKey, flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
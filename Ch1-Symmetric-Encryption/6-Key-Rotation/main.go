@@ -0,0 +1,314 @@
+/*
+Key Rotation
+In debugEncryptDecrypt every ciphertext is tied to one hard-coded masterKey, and nothing in the hex
+output says which key produced it. That makes it impossible to ever change the key: the moment we
+swap it, every stored password becomes unreadable.
+
+The fix is to make ciphertexts self-describing. Each one starts with a small header:
+
+version (1 byte) | key ID (4 bytes, big endian) | iv (16 bytes) | ciphertext | tag (32 bytes)
+
+The header is covered by the HMAC tag, so nobody can relabel a ciphertext with a different key ID.
+The tag also covers the name of the record, which isn't stored in the ciphertext. Otherwise a valid
+ciphertext could be copied from one record to another, say from "email" to "bank", and would
+decrypt without any error.
+
+A keyring holds several master keys by ID. New data is always encrypted under the newest key, but
+any key still in the ring can decrypt. Rotating means:
+
+1. Add a new key to the ring. New writes use it straight away.
+2. Re-encrypt existing records under the new key. This can happen in batches, and while it runs
+   some records use the old key and some use the new one. Both still decrypt.
+3. Once no record uses the old key, retire it from the ring.
+*/
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+)
+
+const (
+	formatVersion = 1
+	headerSize    = 1 + 4
+	ivSize        = aes.BlockSize
+	tagSize       = sha256.Size
+)
+
+var (
+	errTooShort   = errors.New("ciphertext is too short")
+	errAuthFailed = errors.New("message authentication failed")
+)
+
+type keyring struct {
+	keys    map[uint32]string
+	current uint32
+}
+
+func newKeyring(id uint32, masterKey string) *keyring {
+	return &keyring{
+		keys:    map[uint32]string{id: masterKey},
+		current: id,
+	}
+}
+
+// add stores a new master key and makes it the one used for new encryptions
+func (kr *keyring) add(id uint32, masterKey string) error {
+	if _, ok := kr.keys[id]; ok {
+		return fmt.Errorf("key %d already exists", id)
+	}
+	kr.keys[id] = masterKey
+	kr.current = id
+	return nil
+}
+
+// retire removes an old key. The current key can't be retired.
+func (kr *keyring) retire(id uint32) error {
+	if id == kr.current {
+		return fmt.Errorf("key %d is the current key", id)
+	}
+	if _, ok := kr.keys[id]; !ok {
+		return fmt.Errorf("unknown key %d", id)
+	}
+	delete(kr.keys, id)
+	return nil
+}
+
+func (kr *keyring) get(id uint32) (string, error) {
+	masterKey, ok := kr.keys[id]
+	if !ok {
+		return "", fmt.Errorf("unknown key %d", id)
+	}
+	return masterKey, nil
+}
+
+func deriveKeys(masterKey string) (encKey, macKey []byte) {
+	encKey = hmacSum([]byte(masterKey), []byte("passly encryption key"))
+	macKey = hmacSum([]byte(masterKey), []byte("passly mac key"))
+	return encKey, macKey
+}
+
+// recordName encodes the record name for the MAC, with its length first so that
+// name and iv can't be shifted into each other
+func recordName(name string) []byte {
+	out := make([]byte, 4, 4+len(name))
+	binary.BigEndian.PutUint32(out, uint32(len(name)))
+	return append(out, name...)
+}
+
+func hmacSum(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// keyID reads the key ID out of a ciphertext header without decrypting it
+func keyID(cipherText string) (uint32, error) {
+	raw, err := hex.DecodeString(cipherText)
+	if err != nil {
+		return 0, err
+	}
+	if len(raw) < headerSize+ivSize+tagSize {
+		return 0, errTooShort
+	}
+	if raw[0] != formatVersion {
+		return 0, fmt.Errorf("unsupported format version %d", raw[0])
+	}
+	return binary.BigEndian.Uint32(raw[1:headerSize]), nil
+}
+
+// encrypt encrypts plainText for the record called name, it only decrypts under the same name
+func encrypt(plainText, name string, kr *keyring) (string, error) {
+	masterKey, err := kr.get(kr.current)
+	if err != nil {
+		return "", err
+	}
+	encKey, macKey := deriveKeys(masterKey)
+	blockCipher, err := aes.NewCipher(encKey)
+	if err != nil {
+		return "", err
+	}
+
+	header := make([]byte, headerSize)
+	header[0] = formatVersion
+	binary.BigEndian.PutUint32(header[1:], kr.current)
+
+	iv := make([]byte, ivSize)
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+
+	bytes := []byte(plainText)
+	stream := cipher.NewCTR(blockCipher, iv)
+	stream.XORKeyStream(bytes, bytes)
+
+	tag := hmacSum(macKey, header, recordName(name), iv, bytes)
+
+	out := append(header, iv...)
+	out = append(out, bytes...)
+	out = append(out, tag...)
+	return fmt.Sprintf("%x", out), nil
+}
+
+func decrypt(cipherText, name string, kr *keyring) (string, error) {
+	id, err := keyID(cipherText)
+	if err != nil {
+		return "", err
+	}
+	masterKey, err := kr.get(id)
+	if err != nil {
+		return "", err
+	}
+
+	raw, _ := hex.DecodeString(cipherText)
+	header := raw[:headerSize]
+	iv := raw[headerSize : headerSize+ivSize]
+	bytes := raw[headerSize+ivSize : len(raw)-tagSize]
+	tag := raw[len(raw)-tagSize:]
+
+	encKey, macKey := deriveKeys(masterKey)
+	if !hmac.Equal(tag, hmacSum(macKey, header, recordName(name), iv, bytes)) {
+		return "", errAuthFailed
+	}
+
+	blockCipher, err := aes.NewCipher(encKey)
+	if err != nil {
+		return "", err
+	}
+	stream := cipher.NewCTR(blockCipher, iv)
+	stream.XORKeyStream(bytes, bytes)
+	return string(bytes), nil
+}
+
+// rotate re-encrypts up to limit records that aren't under the current key.
+// A limit of 0 or less means no limit. It returns how many records were rotated,
+// so callers can run it in batches until it returns 0.
+func rotate(records map[string]string, kr *keyring, limit int) (int, error) {
+	names := []string{}
+	for name := range records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rotated := 0
+	for _, name := range names {
+		if limit > 0 && rotated >= limit {
+			break
+		}
+		id, err := keyID(records[name])
+		if err != nil {
+			return rotated, fmt.Errorf("record %q: %w", name, err)
+		}
+		if id == kr.current {
+			continue
+		}
+		plainText, err := decrypt(records[name], name, kr)
+		if err != nil {
+			return rotated, fmt.Errorf("record %q: %w", name, err)
+		}
+		cipherText, err := encrypt(plainText, name, kr)
+		if err != nil {
+			return rotated, fmt.Errorf("record %q: %w", name, err)
+		}
+		records[name] = cipherText
+		rotated++
+	}
+	return rotated, nil
+}
+
+// keyUsage counts how many records use each key ID
+func keyUsage(records map[string]string) map[uint32]int {
+	usage := map[uint32]int{}
+	for _, cipherText := range records {
+		id, err := keyID(cipherText)
+		if err != nil {
+			continue
+		}
+		usage[id]++
+	}
+	return usage
+}
+
+func debugRecords(records map[string]string, kr *keyring) {
+	names := []string{}
+	for name := range records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		id, _ := keyID(records[name])
+		password, err := decrypt(records[name], name, kr)
+		if err != nil {
+			fmt.Printf("  %v (key %v): %v\n", name, id, err)
+			continue
+		}
+		fmt.Printf("  %v (key %v): %v\n", name, id, password)
+	}
+	fmt.Printf("  key usage: %v\n", keyUsage(records))
+}
+
+func main() {
+	kr := newKeyring(1, "kjhgfdsaqwertyuioplkjhgfdsaqwert")
+
+	records := map[string]string{}
+	passwords := map[string]string{
+		"bank":    "k33pThisPasswordSafe",
+		"email":   "12345",
+		"luggage": "thePasswordOnMyLuggage",
+		"pizza":   "pizza_the_HUt",
+	}
+	for name, password := range passwords {
+		cipherText, err := encrypt(password, name, kr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		records[name] = cipherText
+	}
+	fmt.Println("All records under key 1:")
+	debugRecords(records, kr)
+	fmt.Println("========")
+
+	if err := kr.add(2, "zxcvbnmasdfghjklqwertyuiopzxcvbn"); err != nil {
+		log.Fatal(err)
+	}
+	n, err := rotate(records, kr, 2)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Rotation in progress, %v records moved to key 2:\n", n)
+	debugRecords(records, kr)
+	fmt.Println("========")
+
+	for {
+		n, err := rotate(records, kr, 2)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if n == 0 {
+			break
+		}
+	}
+	if err := kr.retire(1); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Rotation finished and key 1 retired:")
+	debugRecords(records, kr)
+	fmt.Println("========")
+
+	records["bank"] = records["email"]
+	fmt.Println("The email ciphertext copied over the bank record:")
+	debugRecords(records, kr)
+	fmt.Println("========")
+}
@@ -0,0 +1,283 @@
+/*
+Passphrase Keys
+keyToCipher passes the raw string straight into aes.NewCipher, so it only works when the passphrase
+happens to be exactly 16, 24 or 32 bytes long. Humans don't pick passphrases like that, and even if
+they did, a passphrase has far less entropy than 32 random bytes.
+
+Instead we run the passphrase through a key derivation function (KDF) to get a 32-byte AES key:
+
+PBKDF2   - HMAC-SHA256 iterated many times. Old and everywhere, but cheap to attack with GPUs.
+scrypt   - memory-hard, so attackers can't just throw lots of small cores at it.
+Argon2id - the winner of the Password Hashing Competition, memory-hard and the best default today.
+
+Every KDF needs a random salt, and the cost parameters decide how slow it is. Like bcrypt, we store
+the KDF name, its parameters and the salt right next to the ciphertext:
+
+$argon2id$t=1,m=65536,p=4$<salt>$<nonce + ciphertext>
+
+The salt and ciphertext parts are base64 (raw, no padding). Decryption only needs the passphrase:
+it reads the parameters back out of the string and re-derives the same key.
+
+Those parameters come from whoever wrote the ciphertext, and the key has to be derived before GCM
+can check anything. A crafted "$argon2id$t=4294967295,m=4294967295,p=255$..." would make us try to
+allocate 4 TiB and hang forever, so parseParams rejects anything above fixed limits before a single
+byte is derived.
+*/
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	keySize  = 32
+	saltSize = 16
+)
+
+var b64 = base64.RawStdEncoding
+
+// kdfParams describes a KDF and its cost parameters. Only the fields used
+// by the chosen KDF are encoded.
+type kdfParams struct {
+	Name string
+
+	// pbkdf2
+	Iterations int
+
+	// scrypt
+	N, R, P int
+
+	// argon2id
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+}
+
+// limits for parameters read from a ciphertext, far above the defaults below but low enough
+// that a crafted ciphertext can't exhaust memory or keep us busy for hours
+const (
+	maxIterations   = 10_000_000
+	maxScryptN      = 1 << 20
+	maxScryptR      = 32
+	maxScryptP      = 16
+	maxScryptMemory = 1 << 30 // bytes, scrypt uses 128 * N * r
+	maxArgonTime    = 10
+	maxArgonMemory  = 1 << 20 // KiB, 1 GiB
+	maxArgonThreads = 16
+)
+
+var (
+	pbkdf2Params   = kdfParams{Name: "pbkdf2", Iterations: 600000}
+	scryptParams   = kdfParams{Name: "scrypt", N: 1 << 15, R: 8, P: 1}
+	argon2idParams = kdfParams{Name: "argon2id", Time: 1, Memory: 64 * 1024, Threads: 4}
+)
+
+func deriveKey(passphrase string, salt []byte, p kdfParams) ([]byte, error) {
+	switch p.Name {
+	case "pbkdf2":
+		if p.Iterations < 1 {
+			return nil, errors.New("pbkdf2: iterations must be positive")
+		}
+		return pbkdf2.Key([]byte(passphrase), salt, p.Iterations, keySize, sha256.New), nil
+	case "scrypt":
+		return scrypt.Key([]byte(passphrase), salt, p.N, p.R, p.P, keySize)
+	case "argon2id":
+		if p.Time < 1 || p.Memory < 8*uint32(p.Threads) || p.Threads < 1 {
+			return nil, errors.New("argon2id: invalid parameters")
+		}
+		return argon2.IDKey([]byte(passphrase), salt, p.Time, p.Memory, p.Threads, keySize), nil
+	}
+	return nil, fmt.Errorf("unknown kdf %q", p.Name)
+}
+
+func (p kdfParams) String() string {
+	switch p.Name {
+	case "pbkdf2":
+		return fmt.Sprintf("i=%d", p.Iterations)
+	case "scrypt":
+		return fmt.Sprintf("N=%d,r=%d,p=%d", p.N, p.R, p.P)
+	case "argon2id":
+		return fmt.Sprintf("t=%d,m=%d,p=%d", p.Time, p.Memory, p.Threads)
+	}
+	return ""
+}
+
+func parseParams(name, s string) (kdfParams, error) {
+	p := kdfParams{Name: name}
+	for _, field := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok {
+			return p, fmt.Errorf("malformed parameter %q", field)
+		}
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return p, fmt.Errorf("parameter %q: %w", k, err)
+		}
+		switch name + ":" + k {
+		case "pbkdf2:i":
+			p.Iterations = int(n)
+		case "scrypt:N":
+			p.N = int(n)
+		case "scrypt:r":
+			p.R = int(n)
+		case "scrypt:p":
+			p.P = int(n)
+		case "argon2id:t":
+			p.Time = uint32(n)
+		case "argon2id:m":
+			p.Memory = uint32(n)
+		case "argon2id:p":
+			if n > 255 {
+				return p, fmt.Errorf("parameter %q out of range", k)
+			}
+			p.Threads = uint8(n)
+		default:
+			return p, fmt.Errorf("unknown parameter %q for %s", k, name)
+		}
+	}
+	if err := p.checkLimits(); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// checkLimits rejects parameters that would make deriving the key too expensive
+func (p kdfParams) checkLimits() error {
+	switch p.Name {
+	case "pbkdf2":
+		if p.Iterations > maxIterations {
+			return fmt.Errorf("pbkdf2: %d iterations is above the limit of %d", p.Iterations, maxIterations)
+		}
+	case "scrypt":
+		if p.N > maxScryptN || p.R > maxScryptR || p.P > maxScryptP {
+			return fmt.Errorf("scrypt: N=%d,r=%d,p=%d is above the limit of N=%d,r=%d,p=%d",
+				p.N, p.R, p.P, maxScryptN, maxScryptR, maxScryptP)
+		}
+		if 128*p.N*p.R > maxScryptMemory {
+			return fmt.Errorf("scrypt: N=%d,r=%d needs more than %d bytes of memory", p.N, p.R, maxScryptMemory)
+		}
+	case "argon2id":
+		if p.Time > maxArgonTime || p.Memory > maxArgonMemory || p.Threads > maxArgonThreads {
+			return fmt.Errorf("argon2id: t=%d,m=%d,p=%d is above the limit of t=%d,m=%d,p=%d",
+				p.Time, p.Memory, p.Threads, maxArgonTime, maxArgonMemory, maxArgonThreads)
+		}
+	default:
+		return fmt.Errorf("unknown kdf %q", p.Name)
+	}
+	return nil
+}
+
+// passphraseToCipher accepts a passphrase of any length
+func passphraseToCipher(passphrase string, salt []byte, p kdfParams) (cipher.Block, error) {
+	key, err := deriveKey(passphrase, salt, p)
+	if err != nil {
+		return nil, err
+	}
+	return aes.NewCipher(key)
+}
+
+func encrypt(plainText, passphrase string, p kdfParams) (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	block, err := passphraseToCipher(passphrase, salt, p)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plainText), nil)
+	return fmt.Sprintf("$%s$%s$%s$%s", p.Name, p, b64.EncodeToString(salt), b64.EncodeToString(sealed)), nil
+}
+
+func decrypt(cipherText, passphrase string) (string, error) {
+	parts := strings.Split(cipherText, "$")
+	if len(parts) != 5 || parts[0] != "" {
+		return "", errors.New("malformed ciphertext")
+	}
+	p, err := parseParams(parts[1], parts[2])
+	if err != nil {
+		return "", err
+	}
+	salt, err := b64.DecodeString(parts[3])
+	if err != nil {
+		return "", fmt.Errorf("salt: %w", err)
+	}
+	sealed, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return "", fmt.Errorf("ciphertext: %w", err)
+	}
+
+	block, err := passphraseToCipher(passphrase, salt, p)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("ciphertext is too short")
+	}
+	nonce, sealed := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plainText, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", errors.New("wrong passphrase or corrupted ciphertext")
+	}
+	return string(plainText), nil
+}
+
+func test(passphrase, wrongPassphrase, plainText string, p kdfParams) {
+	defer fmt.Println("========")
+	fmt.Printf("Encrypting '%s' with passphrase '%s' using %s...\n", plainText, passphrase, p.Name)
+	cipherText, err := encrypt(plainText, passphrase, p)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Ciphertext: %v\n", cipherText)
+	decrypted, err := decrypt(cipherText, passphrase)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Decrypted: %v\n", decrypted)
+	_, err = decrypt(cipherText, wrongPassphrase)
+	fmt.Printf("Decrypting with '%s': %v\n", wrongPassphrase, err)
+}
+
+func main() {
+	test("hunter2", "hunter3", "k33pThisPasswordSafe", pbkdf2Params)
+	test("correct horse battery staple", "correct horse battery stapler", "12345", scryptParams)
+	test("thisIsMySecretKeyIHopeNoOneFinds", "thisIsMySecretKeyIHopeNoOneFind", "pizza_the_HUt", argon2idParams)
+
+	// ciphertexts that ask for absurd costs are rejected before anything is derived
+	for _, crafted := range []string{
+		"$argon2id$t=4294967295,m=4294967295,p=255$c2FsdA$AAAA",
+		"$pbkdf2$i=4294967295$c2FsdA$AAAA",
+		"$scrypt$N=1048576,r=32,p=1$c2FsdA$AAAA",
+	} {
+		_, err := decrypt(crafted, "hunter2")
+		fmt.Printf("Decrypting %s: %v\n", crafted, err)
+	}
+}
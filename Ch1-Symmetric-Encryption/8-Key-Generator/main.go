@@ -0,0 +1,193 @@
+/*
+Key Generator
+In Single-Use Keys, generateRandomKey read its bytes from math/rand seeded with 0. That made the
+output reproducible, which is nice for a lesson, but it also means anyone can reproduce our keys.
+math/rand is a pseudo-random generator meant for simulations. Keys must come from a
+cryptographically secure random number generator (CSPRNG): crypto/rand.
+
+We still want reproducible output in tests, so the generator takes an io.Reader. In production it's
+crypto/rand.Reader. In a test you can hand it anything, including a seeded math/rand source.
+
+Keys also need to be shown to humans, and some formats are easier to work with than others:
+
+hex        - ddda0e759e1c1c88...   easy to read, but twice as long as the key
+base64url  - 3doOdZ4cHIhh81B1...   short and safe in URLs and file names
+crockford  - VQD0-WSMY-3GE8-...    base32 without I, L, O or U, so it's hard to misread.
+                                   It's case-insensitive and reads O as 0 and I/L as 1.
+checksum   - crockford plus a check symbol, so a typo is caught before the key is used
+
+The check symbol follows Crockford's spec: the encoded value mod 37, written with the 32 normal
+symbols plus "*~$=U". It catches any single wrong character and any swap of two neighbouring
+characters.
+*/
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"strings"
+)
+
+const (
+	crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	checkAlphabet     = crockfordAlphabet + "*~$=U"
+	groupSize         = 4
+)
+
+var crockford = base32.NewEncoding(crockfordAlphabet).WithPadding(base32.NoPadding)
+
+var errBadChecksum = errors.New("checksum mismatch: the key was mistyped")
+
+type keyGenerator struct {
+	rand io.Reader
+}
+
+// newKeyGenerator returns a generator that reads from r.
+// A nil reader means crypto/rand.
+func newKeyGenerator(r io.Reader) *keyGenerator {
+	if r == nil {
+		r = rand.Reader
+	}
+	return &keyGenerator{rand: r}
+}
+
+func (g *keyGenerator) generate(length int) ([]byte, error) {
+	if length <= 0 {
+		return nil, fmt.Errorf("invalid key length %d", length)
+	}
+	key := make([]byte, length)
+	// io.ReadFull so a short read is an error, not a key with trailing zeroes
+	if _, err := io.ReadFull(g.rand, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func formatHex(key []byte) string {
+	return fmt.Sprintf("%x", key)
+}
+
+func formatBase64URL(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+// formatCrockford encodes the key in Crockford base32, split into dash separated groups
+func formatCrockford(key []byte) string {
+	return group(crockford.EncodeToString(key))
+}
+
+// formatChecksum is formatCrockford with a trailing check symbol
+func formatChecksum(key []byte) string {
+	encoded := crockford.EncodeToString(key)
+	return group(encoded + string(checkSymbol(encoded)))
+}
+
+func group(s string) string {
+	groups := []string{}
+	for len(s) > groupSize {
+		groups = append(groups, s[:groupSize])
+		s = s[groupSize:]
+	}
+	groups = append(groups, s)
+	return strings.Join(groups, "-")
+}
+
+// checkSymbol treats the base32 string as one big number and returns
+// the symbol for its value mod 37
+func checkSymbol(encoded string) byte {
+	mod := 0
+	for i := 0; i < len(encoded); i++ {
+		mod = (mod*32 + strings.IndexByte(crockfordAlphabet, encoded[i])) % 37
+	}
+	return checkAlphabet[mod]
+}
+
+// normalize undoes the things humans do when typing a key:
+// lowercase, dashes, spaces, and the letters that look like digits
+func normalize(s string) string {
+	s = strings.ToUpper(s)
+	s = strings.NewReplacer("-", "", " ", "", "O", "0", "I", "1", "L", "1").Replace(s)
+	return s
+}
+
+func parseCrockford(s string) ([]byte, error) {
+	return crockford.DecodeString(normalize(s))
+}
+
+func parseChecksum(s string) ([]byte, error) {
+	s = normalize(s)
+	if len(s) < 2 {
+		return nil, errors.New("key is too short")
+	}
+	encoded, check := s[:len(s)-1], s[len(s)-1]
+	if strings.IndexByte(checkAlphabet, check) < 0 {
+		return nil, fmt.Errorf("invalid check symbol %q", check)
+	}
+	for i := 0; i < len(encoded); i++ {
+		if strings.IndexByte(crockfordAlphabet, encoded[i]) < 0 {
+			return nil, fmt.Errorf("invalid character %q", encoded[i])
+		}
+	}
+	if checkSymbol(encoded) != check {
+		return nil, errBadChecksum
+	}
+	return crockford.DecodeString(encoded)
+}
+
+func test(g *keyGenerator, length int) {
+	defer fmt.Println("========")
+	key, err := g.generate(length)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("%v-byte key\n", length)
+	fmt.Printf("  hex:       %v\n", formatHex(key))
+	fmt.Printf("  base64url: %v\n", formatBase64URL(key))
+	fmt.Printf("  crockford: %v\n", formatCrockford(key))
+	fmt.Printf("  checksum:  %v\n", formatChecksum(key))
+}
+
+func testTypo(typed string) {
+	key, err := parseChecksum(typed)
+	if err != nil {
+		fmt.Printf("Typed %v: %v\n", typed, err)
+		return
+	}
+	fmt.Printf("Typed %v: ok, key %x\n", typed, key)
+}
+
+func main() {
+	fmt.Println("Keys from crypto/rand:")
+	g := newKeyGenerator(nil)
+	for _, length := range []int{16, 24, 32} {
+		test(g, length)
+	}
+
+	fmt.Println("Reproducible keys from a seeded reader (tests only!):")
+	seeded := newKeyGenerator(mrand.New(mrand.NewSource(0)))
+	test(seeded, 16)
+
+	key, _ := newKeyGenerator(mrand.New(mrand.NewSource(0))).generate(16)
+	original := formatChecksum(key)
+	parsed, err := parseCrockford(strings.ToLower(formatCrockford(key)))
+	fmt.Printf("Parsed lowercase crockford key: %x (err: %v)\n", parsed, err)
+	fmt.Printf("Checksummed key: %v\n", original)
+	testTypo(original)
+	testTypo(strings.ToLower(original))
+	testTypo(strings.ReplaceAll(original, "-", " "))
+	// a wrong character
+	typo := []byte(original)
+	typo[2] = crockfordAlphabet[(strings.IndexByte(crockfordAlphabet, typo[2])+1)%32]
+	testTypo(string(typo))
+	// two neighbouring characters swapped
+	swapped := []byte(original)
+	swapped[5], swapped[6] = swapped[6], swapped[5]
+	testTypo(string(swapped))
+}
@@ -0,0 +1,358 @@
+/*
+Hybrid Encryption
+Symmetric ciphers like AES are fast and can encrypt any amount of data, but both sides need the same
+key. Asymmetric ciphers like RSA solve the key sharing problem (anyone can encrypt with a public
+key, only the owner of the private key can decrypt), but they are slow and can only encrypt a few
+hundred bytes.
+
+Real systems (TLS, PGP, age, cloud KMS "envelope encryption") use both:
+
+1. Generate a random AES "data key" for this one message.
+2. Encrypt the message with the data key using AES-GCM.
+3. "Wrap" (encrypt) the data key for every recipient with their public key.
+4. Ship the wrapped keys and the ciphertext together in an envelope.
+
+A recipient unwraps the data key with their private key, then decrypts the message. Adding another
+recipient only costs one more wrapped 32-byte key, not another copy of the message.
+
+We support two ways to wrap the data key:
+
+RSA-OAEP     - encrypt the data key directly with the recipient's RSA public key.
+X25519+HKDF  - do an ECDH key agreement between a fresh ephemeral key and the recipient's public
+               key, run the shared secret through HKDF to get a key-encryption key (KEK), then
+               encrypt the data key with AES-GCM under the KEK.
+
+The envelope is JSON and self-describing: every recipient entry names the algorithm used, so the
+reader knows how to unwrap it.
+
+Everything outside the ciphertexts is authenticated too, otherwise an attacker could edit it
+without anyone noticing:
+- Each wrapped key is bound to its own recipient header (envelope version, content algorithm, kid,
+  wrap algorithm and ephemeral key). RSA-OAEP takes it as the label, AES-GCM as additional data.
+- The message itself is sealed with the whole envelope header as additional data, including every
+  recipient entry. Adding, removing or editing a recipient makes decryption fail for everyone.
+*/
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	envelopeVersion = 1
+	dataKeySize     = 32
+	gcmNonceSize    = 12
+
+	algRSAOAEP     = "RSA-OAEP-256"
+	algX25519HKDF  = "X25519-HKDF-SHA256"
+	algContent     = "A256GCM"
+	hkdfInfoString = "passly envelope key wrap"
+)
+
+var errNoRecipient = errors.New("envelope has no entry for this recipient")
+
+// envelope is everything a recipient needs to decrypt the message.
+// []byte fields are base64 in the JSON output.
+type envelope struct {
+	Version    int         `json:"version"`
+	Alg        string      `json:"alg"`
+	Recipients []recipient `json:"recipients"`
+	Nonce      []byte      `json:"nonce"`
+	Ciphertext []byte      `json:"ciphertext"`
+}
+
+type recipient struct {
+	KeyID        string `json:"kid"`
+	Alg          string `json:"alg"`
+	EphemeralKey []byte `json:"epk,omitempty"`
+	WrappedKey   []byte `json:"wrapped_key"`
+}
+
+// publicKey is a recipient's public key: either *rsa.PublicKey or *ecdh.PublicKey
+type publicKey struct {
+	keyID string
+	key   any
+}
+
+// privateKey is a recipient's private key: either *rsa.PrivateKey or *ecdh.PrivateKey
+type privateKey struct {
+	keyID string
+	key   any
+}
+
+func seal(plainText []byte, recipients []publicKey) (*envelope, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("at least one recipient is required")
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	env := &envelope{
+		Version: envelopeVersion,
+		Alg:     algContent,
+	}
+	for _, pub := range recipients {
+		r, err := wrapKey(dataKey, pub)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %q: %w", pub.keyID, err)
+		}
+		env.Recipients = append(env.Recipients, r)
+	}
+
+	// the recipients have to be final before the message is sealed, they're part of the header
+	header, err := env.header()
+	if err != nil {
+		return nil, err
+	}
+	env.Nonce, env.Ciphertext, err = aesGCMSeal(dataKey, plainText, header)
+	if err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// header is the additional data for the message: everything in the envelope but the nonce
+// and the ciphertext. encoding/json always writes struct fields in the same order.
+func (env *envelope) header() ([]byte, error) {
+	return json.Marshal(struct {
+		Version    int         `json:"version"`
+		Alg        string      `json:"alg"`
+		Recipients []recipient `json:"recipients"`
+	}{env.Version, env.Alg, env.Recipients})
+}
+
+// wrapHeader is the additional data for one wrapped key: the recipient entry without the
+// wrapped key itself, and the envelope fields that decide how the data key is used
+func wrapHeader(kid, alg string, ephemeralKey []byte) ([]byte, error) {
+	return json.Marshal(struct {
+		Version      int    `json:"version"`
+		ContentAlg   string `json:"content_alg"`
+		KeyID        string `json:"kid"`
+		Alg          string `json:"alg"`
+		EphemeralKey []byte `json:"epk,omitempty"`
+	}{envelopeVersion, algContent, kid, alg, ephemeralKey})
+}
+
+func open(env *envelope, priv privateKey) ([]byte, error) {
+	if env.Version != envelopeVersion || env.Alg != algContent {
+		return nil, fmt.Errorf("unsupported envelope version %d / %s", env.Version, env.Alg)
+	}
+	for _, r := range env.Recipients {
+		if r.KeyID != priv.keyID {
+			continue
+		}
+		dataKey, err := unwrapKey(r, priv)
+		if err != nil {
+			return nil, fmt.Errorf("unwrapping data key: %w", err)
+		}
+		header, err := env.header()
+		if err != nil {
+			return nil, err
+		}
+		return aesGCMOpen(dataKey, env.Nonce, env.Ciphertext, header)
+	}
+	return nil, errNoRecipient
+}
+
+func wrapKey(dataKey []byte, pub publicKey) (recipient, error) {
+	switch key := pub.key.(type) {
+	case *rsa.PublicKey:
+		label, err := wrapHeader(pub.keyID, algRSAOAEP, nil)
+		if err != nil {
+			return recipient{}, err
+		}
+		wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, key, dataKey, label)
+		if err != nil {
+			return recipient{}, err
+		}
+		return recipient{KeyID: pub.keyID, Alg: algRSAOAEP, WrappedKey: wrapped}, nil
+	case *ecdh.PublicKey:
+		ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return recipient{}, err
+		}
+		kek, err := deriveKEK(ephemeral, key, ephemeral.PublicKey())
+		if err != nil {
+			return recipient{}, err
+		}
+		epk := ephemeral.PublicKey().Bytes()
+		header, err := wrapHeader(pub.keyID, algX25519HKDF, epk)
+		if err != nil {
+			return recipient{}, err
+		}
+		nonce, wrapped, err := aesGCMSeal(kek, dataKey, header)
+		if err != nil {
+			return recipient{}, err
+		}
+		return recipient{
+			KeyID:        pub.keyID,
+			Alg:          algX25519HKDF,
+			EphemeralKey: epk,
+			WrappedKey:   append(nonce, wrapped...),
+		}, nil
+	}
+	return recipient{}, fmt.Errorf("unsupported public key type %T", pub.key)
+}
+
+func unwrapKey(r recipient, priv privateKey) ([]byte, error) {
+	header, err := wrapHeader(r.KeyID, r.Alg, r.EphemeralKey)
+	if err != nil {
+		return nil, err
+	}
+	switch key := priv.key.(type) {
+	case *rsa.PrivateKey:
+		if r.Alg != algRSAOAEP {
+			break
+		}
+		return rsa.DecryptOAEP(sha256.New(), nil, key, r.WrappedKey, header)
+	case *ecdh.PrivateKey:
+		if r.Alg != algX25519HKDF {
+			break
+		}
+		ephemeral, err := ecdh.X25519().NewPublicKey(r.EphemeralKey)
+		if err != nil {
+			return nil, err
+		}
+		kek, err := deriveKEK(key, ephemeral, ephemeral)
+		if err != nil {
+			return nil, err
+		}
+		if len(r.WrappedKey) < gcmNonceSize {
+			return nil, errors.New("wrapped key is too short")
+		}
+		return aesGCMOpen(kek, r.WrappedKey[:gcmNonceSize], r.WrappedKey[gcmNonceSize:], header)
+	}
+	return nil, fmt.Errorf("key type %T can't unwrap %s", priv.key, r.Alg)
+}
+
+// deriveKEK runs X25519 between priv and peer, then HKDF over the shared secret.
+// The ephemeral public key is used as the salt so every wrap gets its own KEK.
+func deriveKEK(priv *ecdh.PrivateKey, peer, ephemeral *ecdh.PublicKey) ([]byte, error) {
+	shared, err := priv.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+	kek := make([]byte, dataKeySize)
+	r := hkdf.New(sha256.New, shared, ephemeral.Bytes(), []byte(hkdfInfoString))
+	if _, err := io.ReadFull(r, kek); err != nil {
+		return nil, err
+	}
+	return kek, nil
+}
+
+func aesGCMSeal(key, plainText, additionalData []byte) (nonce, cipherText []byte, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, gcm.Seal(nil, nonce, plainText, additionalData), nil
+}
+
+func aesGCMOpen(key, nonce, cipherText, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid nonce size")
+	}
+	return gcm.Open(nil, nonce, cipherText, additionalData)
+}
+
+func test(env *envelope, priv privateKey) {
+	plainText, err := open(env, priv)
+	if err != nil {
+		fmt.Printf("%v can't open the envelope: %v\n", priv.keyID, err)
+		return
+	}
+	fmt.Printf("%v decrypted: %s\n", priv.keyID, plainText)
+}
+
+func main() {
+	aliceRSA, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal(err)
+	}
+	bobX25519, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	eveX25519, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	env, err := seal([]byte("k33pThisPasswordSafe"), []publicKey{
+		{keyID: "alice", key: &aliceRSA.PublicKey},
+		{keyID: "bob", key: bobX25519.PublicKey()},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Envelope:\n%s\n", out)
+	fmt.Println("========")
+
+	// round trip through JSON like a real recipient would
+	received := &envelope{}
+	if err := json.Unmarshal(out, received); err != nil {
+		log.Fatal(err)
+	}
+	test(received, privateKey{keyID: "alice", key: aliceRSA})
+	test(received, privateKey{keyID: "bob", key: bobX25519})
+	test(received, privateKey{keyID: "eve", key: eveX25519})
+	// eve pretends to be bob, but doesn't have bob's private key
+	test(received, privateKey{keyID: "bob", key: eveX25519})
+	fmt.Println("========")
+
+	// edit the header: rename bob's entry, then drop alice's entry
+	renamed := &envelope{}
+	if err := json.Unmarshal(out, renamed); err != nil {
+		log.Fatal(err)
+	}
+	renamed.Recipients[1].KeyID = "bobby"
+	fmt.Println("bob's entry renamed to bobby:")
+	test(renamed, privateKey{keyID: "bobby", key: bobX25519})
+	test(renamed, privateKey{keyID: "alice", key: aliceRSA})
+
+	stripped := &envelope{}
+	if err := json.Unmarshal(out, stripped); err != nil {
+		log.Fatal(err)
+	}
+	stripped.Recipients = stripped.Recipients[1:]
+	fmt.Println("alice's entry removed:")
+	test(stripped, privateKey{keyID: "bob", key: bobX25519})
+	fmt.Println("========")
+}
@@ -3,22 +3,127 @@ package main
 import (
 	"encoding/hex"
 	"fmt"
-	"strings"
 )
 
+// hexSyntaxError says where in the input the hex couldn't be parsed.
+// Line and Column start at 1, Column counts characters, not bytes.
+type hexSyntaxError struct {
+	Line   int
+	Column int
+	Char   rune
+	Msg    string
+}
+
+func (e *hexSyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s %q", e.Line, e.Column, e.Msg, e.Char)
+}
+
+func isHexDigit(c rune) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isSeparator(c rune) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', ':', ',', '-':
+		return true
+	}
+	return false
+}
+
+// getHexBytes accepts the common ways hex is written down:
+//
+//	48:65:6c:6c:6f          colon separated, like getHexString
+//	48656c6c6f              one block, like %x
+//	48 65 6C 6C 6F          spaces, any case
+//	0x48, 0x65, 0x6c        0x prefixes, on every byte or on the whole block
+//	4865 6c6c\n6f           grouped and wrapped over several lines
+//	48656\nc6c6f            a %x block wrapped at any column
+//
+// A line break inside a run of digits continues it, so a block can be wrapped anywhere. Every other
+// separator, or a blank line, ends the group, and each group must have an even number of digits.
 func getHexBytes(s string) ([]byte, error) {
 	final := []byte{}
-	parts := strings.Split(s, ":")
-	for _, v := range parts {
-		dec, err := hex.DecodeString(v)
+	group := []byte{}
+	line, col := 1, 0
+	var groupErr *hexSyntaxError
+
+	endGroup := func() error {
+		if len(group)%2 != 0 {
+			return groupErr
+		}
+		dec, err := hex.DecodeString(string(group))
 		if err != nil {
-			return nil, err
+			return err
 		}
 		final = append(final, dec...)
+		group = group[:0]
+		return nil
+	}
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		col++
+		switch {
+		case c == '\r' && i+1 < len(runes) && runes[i+1] == '\n':
+			// part of a \r\n line break
+		case c == '\n':
+			if blankLineFollows(runes[i+1:]) {
+				if err := endGroup(); err != nil {
+					return nil, err
+				}
+			}
+			line, col = line+1, 0
+		case isSeparator(c):
+			if err := endGroup(); err != nil {
+				return nil, err
+			}
+		case (len(group) == 0 || col == 1) && c == '0' && i+1 < len(runes) && (runes[i+1] == 'x' || runes[i+1] == 'X'):
+			// skip the 0x prefix
+			i++
+			col++
+		case isHexDigit(c):
+			if len(group) == 0 {
+				groupErr = &hexSyntaxError{Line: line, Column: col, Char: c, Msg: "odd number of hex digits in group starting with"}
+			}
+			group = append(group, byte(c))
+		default:
+			return nil, &hexSyntaxError{Line: line, Column: col, Char: c, Msg: "invalid character"}
+		}
+	}
+	if err := endGroup(); err != nil {
+		return nil, err
 	}
 	return final, nil
 }
 
+// blankLineFollows reports whether the line starting at rest has nothing but spaces on it
+func blankLineFollows(rest []rune) bool {
+	for _, c := range rest {
+		switch c {
+		case ' ', '\t', '\r':
+			continue
+		case '\n':
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// init shows the new layouts before main runs the original tests
+func init() {
+	testHex("6b3333705468697350617373776f726453616665")
+	testHex("6B 33 33 70 54 68 69 73")
+	testHex("0x70, 0x69, 0x7a, 0x7A, 0x61")
+	testHex("0x70697a7a61")
+	testHex("7069 7a7a\n615f 7468\r\n655f 4855 74")
+	testHex("6b33337054686973506\n1737377\n6f726453616665")
+	testHex("48:65:6c:6g:6f")
+	testHex("4865\n6c6c 6f5")
+	testHex("486\n\n56c")
+}
+
 // don't touch below this line
 
 func testHex(s string) {
@@ -0,0 +1,363 @@
+/*
+Radix Codec
+base8Char maps a single value between 0 and 7 to one character of "ABCDEFGH". That's an alphabet,
+but not yet an encoding: we can't hand it a stream of bytes and get text back, or go the other way.
+
+There are two ways to turn bytes into symbols of an arbitrary alphabet.
+
+Bit packing (base 2, 4, 8, 16, 32, 64, ...)
+When the base is a power of two, every symbol holds exactly log2(base) bits. We treat the input as
+one long string of bits and cut it into groups:
+
+bytes:   01001000 01101001                  "Hi"
+base 8:  010 010 000 110 100 1(00)          3 bits per symbol, last group padded with zeroes
+symbols: C   C   A   G   E   E
+
+This is how hex, base32 and base64 work, and it can be done in a stream: we only ever need to
+remember the few bits that didn't fill a whole symbol yet.
+
+Big-number conversion (base 58, 62, ...)
+58 isn't a power of two, so a symbol doesn't hold a whole number of bits. Instead the entire input
+is treated as one huge number, and we write that number in base 58, the same way you'd write a
+number in base 10. Leading zero bytes would vanish that way ("007" == "7"), so each one is written
+as the first symbol of the alphabet. Bitcoin addresses use this.
+
+The catch is that the last input byte changes every output symbol, so a big-number encoder has to
+see the whole input before writing anything. Our streaming encoder and decoder buffer until the
+stream ends in that mode.
+
+Decoding errors report the offset of the first symbol that isn't in the alphabet, just like
+base64.CorruptInputError does.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"math/bits"
+	"strings"
+)
+
+const (
+	base8Alphabet  = "ABCDEFGH"
+	base32Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	base64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+)
+
+// corruptInputError is the offset of the first bad symbol in the input
+type corruptInputError int64
+
+func (e corruptInputError) Error() string {
+	return fmt.Sprintf("illegal symbol at input offset %d", int64(e))
+}
+
+type radix struct {
+	alphabet  string
+	base      int
+	bits      uint // bits per symbol, 0 when the base isn't a power of two
+	decodeMap [256]int
+}
+
+func newRadix(alphabet string) (*radix, error) {
+	if len(alphabet) < 2 || len(alphabet) > 256 {
+		return nil, fmt.Errorf("alphabet must have between 2 and 256 symbols, got %d", len(alphabet))
+	}
+	r := &radix{alphabet: alphabet, base: len(alphabet)}
+	for i := range r.decodeMap {
+		r.decodeMap[i] = -1
+	}
+	for i := 0; i < len(alphabet); i++ {
+		if r.decodeMap[alphabet[i]] != -1 {
+			return nil, fmt.Errorf("symbol %q appears twice in the alphabet", alphabet[i])
+		}
+		r.decodeMap[alphabet[i]] = i
+	}
+	// a power of two has exactly one bit set
+	if bits.OnesCount(uint(r.base)) == 1 {
+		r.bits = uint(bits.TrailingZeros(uint(r.base)))
+	}
+	return r, nil
+}
+
+func mustRadix(alphabet string) *radix {
+	r, err := newRadix(alphabet)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (r *radix) encodeToString(src []byte) string {
+	buf := &bytes.Buffer{}
+	enc := newEncoder(r, buf)
+	enc.Write(src)
+	enc.Close()
+	return buf.String()
+}
+
+func (r *radix) decodeString(s string) ([]byte, error) {
+	return io.ReadAll(newDecoder(r, strings.NewReader(s)))
+}
+
+// bigEncode writes src as one big number in base r.base
+func (r *radix) bigEncode(src []byte) []byte {
+	zeroes := 0
+	for zeroes < len(src) && src[zeroes] == 0 {
+		zeroes++
+	}
+	out := []byte{}
+	n := new(big.Int).SetBytes(src[zeroes:])
+	base := big.NewInt(int64(r.base))
+	mod := new(big.Int)
+	for n.Sign() > 0 {
+		n.DivMod(n, base, mod)
+		out = append(out, r.alphabet[mod.Int64()])
+	}
+	for i := 0; i < zeroes; i++ {
+		out = append(out, r.alphabet[0])
+	}
+	// digits came out least significant first
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// bigDecode expects symbols that were already checked against the alphabet
+func (r *radix) bigDecode(symbols []byte) []byte {
+	zeroes := 0
+	for zeroes < len(symbols) && symbols[zeroes] == r.alphabet[0] {
+		zeroes++
+	}
+	n := new(big.Int)
+	base := big.NewInt(int64(r.base))
+	for _, s := range symbols[zeroes:] {
+		n.Mul(n, base)
+		n.Add(n, big.NewInt(int64(r.decodeMap[s])))
+	}
+	return append(make([]byte, zeroes), n.Bytes()...)
+}
+
+// encoder is an io.WriteCloser that writes the encoding of everything written to it.
+// Close must be called to flush the last partial symbol.
+type encoder struct {
+	r      *radix
+	w      io.Writer
+	acc    uint
+	nacc   uint
+	buf    []byte // whole input, big-number mode only
+	closed bool
+}
+
+func newEncoder(r *radix, w io.Writer) io.WriteCloser {
+	return &encoder{r: r, w: w}
+}
+
+func (e *encoder) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed encoder")
+	}
+	if e.r.bits == 0 {
+		e.buf = append(e.buf, p...)
+		return len(p), nil
+	}
+
+	out := make([]byte, 0, len(p)*8/int(e.r.bits)+1)
+	mask := uint(1)<<e.r.bits - 1
+	for _, b := range p {
+		e.acc = e.acc<<8 | uint(b)
+		e.nacc += 8
+		for e.nacc >= e.r.bits {
+			e.nacc -= e.r.bits
+			out = append(out, e.r.alphabet[(e.acc>>e.nacc)&mask])
+		}
+		// only keep the bits that haven't been written yet
+		e.acc &= uint(1)<<e.nacc - 1
+	}
+	if _, err := e.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (e *encoder) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	if e.r.bits == 0 {
+		_, err := e.w.Write(e.r.bigEncode(e.buf))
+		return err
+	}
+	if e.nacc > 0 {
+		// pad the last group with zero bits
+		sym := e.r.alphabet[e.acc<<(e.r.bits-e.nacc)]
+		_, err := e.w.Write([]byte{sym})
+		return err
+	}
+	return nil
+}
+
+// decoder is an io.Reader that decodes the symbols read from src
+type decoder struct {
+	r      *radix
+	src    io.Reader
+	offset int64
+	acc    uint
+	nacc   uint
+	in     []byte
+	out    []byte
+	buf    []byte // all symbols, big-number mode only
+	err    error
+}
+
+func newDecoder(r *radix, src io.Reader) io.Reader {
+	return &decoder{r: r, src: src, in: make([]byte, 1024)}
+}
+
+func (d *decoder) Read(p []byte) (int, error) {
+	for len(d.out) == 0 && d.err == nil {
+		n, err := d.src.Read(d.in)
+		d.decode(d.in[:n])
+		if err != nil && d.err == nil {
+			if err == io.EOF {
+				d.finish()
+			}
+			if d.err == nil {
+				d.err = err
+			}
+		}
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	if len(d.out) > 0 {
+		return n, nil
+	}
+	return n, d.err
+}
+
+func (d *decoder) decode(symbols []byte) {
+	if d.err != nil {
+		return
+	}
+	for _, s := range symbols {
+		v := d.r.decodeMap[s]
+		if v < 0 {
+			d.err = corruptInputError(d.offset)
+			return
+		}
+		d.offset++
+		if d.r.bits == 0 {
+			d.buf = append(d.buf, s)
+			continue
+		}
+		d.acc = d.acc<<d.r.bits | uint(v)
+		d.nacc += d.r.bits
+		if d.nacc >= 8 {
+			d.nacc -= 8
+			d.out = append(d.out, byte(d.acc>>d.nacc))
+			d.acc &= uint(1)<<d.nacc - 1
+		}
+	}
+}
+
+func (d *decoder) finish() {
+	if d.r.bits == 0 {
+		d.out = append(d.out, d.r.bigDecode(d.buf)...)
+		return
+	}
+	// what's left must be the zero padding of the last symbol. A whole
+	// leftover symbol or non-zero padding means the input was cut or garbled.
+	if d.nacc >= d.r.bits || d.acc != 0 {
+		d.err = corruptInputError(d.offset - 1)
+	}
+}
+
+func testBase8(rawMessage []byte) {
+	fmt.Printf("Encoding %08b in custom base 8...\n", rawMessage)
+	encoded := base8Radix.encodeToString(rawMessage)
+	fmt.Printf("Encoded result: %v\n", encoded)
+	decoded, err := base8Radix.decodeString(encoded)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Decoded result: %08b\n", decoded)
+	fmt.Println("========")
+}
+
+func testRoundTrip(name string, r *radix, msg []byte) {
+	encoded := r.encodeToString(msg)
+	decoded, err := r.decodeString(encoded)
+	if err != nil {
+		fmt.Printf("%v: %v\n", name, err)
+		return
+	}
+	fmt.Printf("%-7v %v -> %v (round trip ok: %v)\n", name+":", msg, encoded, bytes.Equal(decoded, msg))
+}
+
+func testBadInput(name string, r *radix, s string) {
+	_, err := r.decodeString(s)
+	fmt.Printf("Decoding %q as %v: %v\n", s, name, err)
+}
+
+var (
+	base8Radix  = mustRadix(base8Alphabet)
+	base32Radix = mustRadix(base32Alphabet)
+	base58Radix = mustRadix(base58Alphabet)
+	base62Radix = mustRadix(base62Alphabet)
+	base64Radix = mustRadix(base64Alphabet)
+)
+
+func main() {
+	testBase8([]byte{0b010, 0b000, 0b001})
+	testBase8([]byte("Hi"))
+
+	msg := []byte{0, 0, 'P', 'a', 's', 's', 'l', 'y'}
+	testRoundTrip("base8", base8Radix, msg)
+	testRoundTrip("base32", base32Radix, msg)
+	testRoundTrip("base58", base58Radix, msg)
+	testRoundTrip("base62", base62Radix, msg)
+	testRoundTrip("base64", base64Radix, msg)
+	fmt.Println("========")
+
+	// bit packing must match the standard library (without padding)
+	std32 := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(msg)
+	std64 := base64.RawStdEncoding.EncodeToString(msg)
+	fmt.Printf("base32 matches encoding/base32: %v\n", base32Radix.encodeToString(msg) == std32)
+	fmt.Printf("base64 matches encoding/base64: %v\n", base64Radix.encodeToString(msg) == std64)
+	fmt.Println("========")
+
+	testBadInput("base8", base8Radix, "CCAGEEZ")
+	testBadInput("base58", base58Radix, "3yQ0")
+	testBadInput("base64", base64Radix, "UGFz!c2x5")
+	testBadInput("base64", base64Radix, "UGFzc2x5U")
+	fmt.Println("========")
+
+	// stream a large message through the encoder and back through the decoder
+	large := bytes.Repeat([]byte("k33pThisPasswordSafe "), 1000)
+	for _, r := range []*radix{base8Radix, base58Radix} {
+		encoded := &bytes.Buffer{}
+		enc := newEncoder(r, encoded)
+		if _, err := io.Copy(enc, bytes.NewReader(large)); err != nil {
+			log.Fatal(err)
+		}
+		if err := enc.Close(); err != nil {
+			log.Fatal(err)
+		}
+		decoded, err := io.ReadAll(newDecoder(r, encoded))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Streamed %v bytes through base %v, round trip ok: %v\n", len(large), r.base, bytes.Equal(decoded, large))
+	}
+}
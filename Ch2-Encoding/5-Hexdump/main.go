@@ -0,0 +1,282 @@
+/*
+Hexdump
+getHexString and getBinaryString print a byte slice as one long colon-joined line. That's fine for
+a 5-byte password, but every later chapter prints ciphertexts, and a 200-byte ciphertext on a single
+line is unreadable. The classic tool for looking at raw bytes is a hexdump, like xxd:
+
+00000000: 6b33 3370 5468 6973 5061 7373 776f 7264  k33pThisPassword
+00000010: 5361 6665 0a                             Safe.
+
+offset column  - where in the input the line starts, in hex
+hex columns    - the bytes, split into groups (2 bytes per group by default)
+ASCII gutter   - the same bytes as text, with non-printable bytes shown as "."
+
+Binary mode (-b) prints every byte as 8 bits instead, like getBinaryString, 6 bytes per line.
+
+Reverse mode (-r) parses a dump back into the original bytes, so you can edit a ciphertext in a
+text editor (flip a bit, say) and turn it back into binary. The ASCII gutter is ignored, only the
+offsets and the hex or binary columns matter.
+
+Usage
+go run . [-c cols] [-g groupsize] [-b] [-r] FILE
+Use "-" as FILE to read stdin. Running with no FILE runs the demo.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type dumpOptions struct {
+	cols   int  // bytes per line
+	group  int  // bytes per group, 0 means no grouping
+	binary bool // 8 bits per byte instead of 2 hex digits
+}
+
+func defaultOptions(binary bool) dumpOptions {
+	if binary {
+		return dumpOptions{cols: 6, group: 1, binary: true}
+	}
+	return dumpOptions{cols: 16, group: 2}
+}
+
+func byteString(b byte, binary bool) string {
+	if binary {
+		return fmt.Sprintf("%08b", b)
+	}
+	return fmt.Sprintf("%02x", b)
+}
+
+func asciiChar(b byte) byte {
+	if b < 0x20 || b > 0x7e {
+		return '.'
+	}
+	return b
+}
+
+// dumpLine formats one line of at most opts.cols bytes starting at offset
+func dumpLine(offset int, line []byte, opts dumpOptions) string {
+	group := opts.group
+	if group <= 0 || group > opts.cols {
+		group = opts.cols
+	}
+
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "%08x: ", offset)
+
+	// the hex area is always padded to the width of a full line
+	// so the ASCII gutter lines up on the last line too
+	digits := 2
+	if opts.binary {
+		digits = 8
+	}
+	numGroups := (opts.cols + group - 1) / group
+	width := opts.cols*digits + numGroups - 1
+	hexArea := strings.Builder{}
+	for i, b := range line {
+		if i > 0 && i%group == 0 {
+			hexArea.WriteByte(' ')
+		}
+		hexArea.WriteString(byteString(b, opts.binary))
+	}
+	fmt.Fprintf(&sb, "%-*s  ", width, hexArea.String())
+
+	for _, b := range line {
+		sb.WriteByte(asciiChar(b))
+	}
+	return sb.String()
+}
+
+func dump(w io.Writer, r io.Reader, opts dumpOptions) error {
+	if opts.cols <= 0 {
+		return fmt.Errorf("invalid number of columns %d", opts.cols)
+	}
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	line := make([]byte, opts.cols)
+	offset := 0
+	for {
+		n, err := io.ReadFull(br, line)
+		if n > 0 {
+			fmt.Fprintln(bw, dumpLine(offset, line[:n], opts))
+			offset += n
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func getDumpString(b []byte, opts dumpOptions) string {
+	sb := &strings.Builder{}
+	dump(sb, bytes.NewReader(b), opts)
+	return sb.String()
+}
+
+// reverseError points at the line of the dump that couldn't be parsed
+type reverseError struct {
+	line int
+	err  error
+}
+
+func (e *reverseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.line, e.err)
+}
+
+func (e *reverseError) Unwrap() error {
+	return e.err
+}
+
+// reverse parses a dump made by dump (or by xxd) and writes the original bytes.
+// Gaps between offsets are filled with zero bytes.
+func reverse(w io.Writer, r io.Reader, binary bool) error {
+	bw := bufio.NewWriter(w)
+	scanner := bufio.NewScanner(r)
+	written := int64(0)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		offset, data, err := parseLine(text, binary)
+		if err != nil {
+			return &reverseError{line: lineNum, err: err}
+		}
+		if offset < written {
+			return &reverseError{line: lineNum, err: fmt.Errorf("offset %08x goes backwards", offset)}
+		}
+		for ; written < offset; written++ {
+			bw.WriteByte(0)
+		}
+		bw.Write(data)
+		written += int64(len(data))
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func parseLine(text string, binary bool) (int64, []byte, error) {
+	offsetStr, rest, ok := strings.Cut(text, ":")
+	if !ok {
+		return 0, nil, errors.New("missing offset")
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(offsetStr), 16, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("bad offset %q", offsetStr)
+	}
+
+	// the byte columns end where the two space ASCII gutter separator starts
+	rest = strings.TrimPrefix(rest, " ")
+	if i := strings.Index(rest, "  "); i >= 0 {
+		rest = rest[:i]
+	}
+
+	data := []byte{}
+	for _, field := range strings.Fields(rest) {
+		b, err := parseField(field, binary)
+		if err != nil {
+			return 0, nil, err
+		}
+		data = append(data, b...)
+	}
+	return offset, data, nil
+}
+
+func parseField(field string, binary bool) ([]byte, error) {
+	digits, base := 2, 16
+	if binary {
+		digits, base = 8, 2
+	}
+	if len(field)%digits != 0 {
+		return nil, fmt.Errorf("group %q has an odd number of digits", field)
+	}
+	data := []byte{}
+	for i := 0; i < len(field); i += digits {
+		v, err := strconv.ParseUint(field[i:i+digits], base, 8)
+		if err != nil {
+			return nil, fmt.Errorf("bad byte %q", field[i:i+digits])
+		}
+		data = append(data, byte(v))
+	}
+	return data, nil
+}
+
+func test(name string, data []byte, opts dumpOptions) {
+	fmt.Printf("%v:\n", name)
+	dumped := getDumpString(data, opts)
+	fmt.Print(dumped)
+	restored := &bytes.Buffer{}
+	if err := reverse(restored, strings.NewReader(dumped), opts.binary); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Reverse matches original: %v\n", bytes.Equal(restored.Bytes(), data))
+	fmt.Println("========")
+}
+
+func main() {
+	cols := flag.Int("c", 0, "bytes per line (default 16, or 6 with -b)")
+	group := flag.Int("g", -1, "bytes per group (default 2, or 1 with -b)")
+	binary := flag.Bool("b", false, "binary digits instead of hex")
+	rev := flag.Bool("r", false, "reverse: turn a dump back into bytes")
+	flag.Parse()
+
+	opts := defaultOptions(*binary)
+	if *cols > 0 {
+		opts.cols = *cols
+	}
+	if *group >= 0 {
+		opts.group = *group
+	}
+
+	if flag.NArg() == 0 {
+		// "k33pThisPasswordSafe" encrypted by Crytography-Encryption
+		cipherText, _ := parseField("e1a08e8c08c45a98a908ec8fd8bf72f14e09866e", false)
+		test("Ciphertext", cipherText, opts)
+		test("Ciphertext in binary", cipherText, defaultOptions(true))
+		test("Text with groups of 4", []byte("k33pThisPasswordSafe\n\tpizza_the_HUt\x00\xff"), dumpOptions{cols: 16, group: 4})
+
+		// a corrupted dump
+		err := reverse(io.Discard, strings.NewReader("00000000: 6b33 3370  k33p\n00000004: 54zz  T?\n"), false)
+		fmt.Println(err)
+		return
+	}
+
+	in := os.Stdin
+	if name := flag.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var err error
+	if *rev {
+		err = reverse(os.Stdout, in, *binary)
+	} else {
+		err = dump(os.Stdout, in, opts)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
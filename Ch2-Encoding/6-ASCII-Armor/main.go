@@ -0,0 +1,347 @@
+/*
+ASCII Armor
+Every chapter prints ciphertexts as one long hex string. That's fragile to pass around: a chat client
+wraps it, an email client adds a space, someone misses the last few characters when copying, and
+the receiver just gets garbage after decrypting (or a confusing error).
+
+PGP solved this decades ago with "ASCII armor" (RFC 4880, section 6):
+
+-----BEGIN PASSLY MESSAGE-----
+Version: 1
+Cipher: AES-256-CTR
+
+egKhnrjXQti+U6W4GzjDl+CVAZQ0kFL8syeHtJcv6prl5O1c
+=U+3b
+-----END PASSLY MESSAGE-----
+
+BEGIN/END lines - mark exactly where the data starts and stops, so it's obvious if it was cut off
+Key: Value      - optional headers, ended by a blank line
+body            - the data in base64, wrapped at 64 columns so no line is too long to paste
+=XXXX           - a CRC-24 checksum of the data, itself in base64
+
+A CRC isn't a MAC: anyone can recompute it, so it doesn't protect against an attacker. It only
+catches accidents, like a changed, missing or duplicated line.
+
+Headers are not encrypted, anyone who sees the message can read them. Put only metadata there, never
+anything secret.
+
+outputFormat lets the encrypt function from the first chapter print either plain hex or armor.
+encrypt picks a new random IV for every message, reusing one in CTR mode reuses the keystream. The
+IV isn't secret, so it goes in front of the ciphertext, and the armored block (or the hex) is all
+the receiver needs besides the key.
+*/
+
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	crc24Init = 0xB704CE
+	crc24Poly = 0x1864CFB
+	lineWidth = 64
+
+	armorBegin = "-----BEGIN "
+	armorEnd   = "-----END "
+	armorDash  = "-----"
+)
+
+var errCRCMismatch = errors.New("armor checksum mismatch: the message was corrupted")
+
+// crc24 is the OpenPGP CRC from RFC 4880, section 6.1
+func crc24(data []byte) uint32 {
+	crc := uint32(crc24Init)
+	for _, b := range data {
+		crc ^= uint32(b) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= crc24Poly
+			}
+		}
+	}
+	return crc & 0xFFFFFF
+}
+
+type header struct {
+	key   string
+	value string
+}
+
+type armorBlock struct {
+	blockType string // e.g. "PASSLY MESSAGE"
+	headers   []header
+	data      []byte
+}
+
+// armorLineError points at the line of the armor that couldn't be parsed
+type armorLineError struct {
+	line int
+	msg  string
+}
+
+func (e *armorLineError) Error() string {
+	return fmt.Sprintf("armor line %d: %s", e.line, e.msg)
+}
+
+func armorEncode(block armorBlock) string {
+	sb := strings.Builder{}
+	sb.WriteString(armorBegin + block.blockType + armorDash + "\n")
+	for _, h := range block.headers {
+		sb.WriteString(h.key + ": " + h.value + "\n")
+	}
+	sb.WriteString("\n")
+
+	body := base64.StdEncoding.EncodeToString(block.data)
+	for len(body) > lineWidth {
+		sb.WriteString(body[:lineWidth] + "\n")
+		body = body[lineWidth:]
+	}
+	if body != "" {
+		sb.WriteString(body + "\n")
+	}
+
+	crc := crc24(block.data)
+	sb.WriteString("=" + base64.StdEncoding.EncodeToString([]byte{byte(crc >> 16), byte(crc >> 8), byte(crc)}) + "\n")
+	sb.WriteString(armorEnd + block.blockType + armorDash + "\n")
+	return sb.String()
+}
+
+// armorDecode parses the first armor block in s. Text before the BEGIN line is ignored,
+// so a message can be pasted along with whatever surrounds it.
+func armorDecode(s string) (armorBlock, error) {
+	block := armorBlock{}
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	lineNum := 0
+	next := func() (string, bool) {
+		if !scanner.Scan() {
+			return "", false
+		}
+		lineNum++
+		return strings.TrimRight(scanner.Text(), " \t\r"), true
+	}
+
+	// BEGIN line
+	for {
+		line, ok := next()
+		if !ok {
+			return block, errors.New("no armor BEGIN line found")
+		}
+		if strings.HasPrefix(line, armorBegin) && strings.HasSuffix(line, armorDash) && len(line) > len(armorBegin)+len(armorDash) {
+			block.blockType = line[len(armorBegin) : len(line)-len(armorDash)]
+			break
+		}
+	}
+
+	// headers until a blank line
+	for {
+		line, ok := next()
+		if !ok {
+			return block, &armorLineError{line: lineNum, msg: "unexpected end of input in headers"}
+		}
+		if line == "" {
+			break
+		}
+		key, value, found := strings.Cut(line, ": ")
+		if !found || key == "" {
+			return block, &armorLineError{line: lineNum, msg: fmt.Sprintf("malformed header %q", line)}
+		}
+		block.headers = append(block.headers, header{key: key, value: value})
+	}
+
+	// body, checksum and END line
+	body := strings.Builder{}
+	checksum := ""
+	for {
+		line, ok := next()
+		if !ok {
+			return block, &armorLineError{line: lineNum, msg: "missing END line, the message was cut off"}
+		}
+		if strings.HasPrefix(line, armorEnd) {
+			if line != armorEnd+block.blockType+armorDash {
+				return block, &armorLineError{line: lineNum, msg: fmt.Sprintf("END line doesn't match BEGIN %q", block.blockType)}
+			}
+			break
+		}
+		if checksum != "" {
+			return block, &armorLineError{line: lineNum, msg: "data after the checksum line"}
+		}
+		if strings.HasPrefix(line, "=") && len(line) == 5 {
+			checksum = line[1:]
+			continue
+		}
+		if len(line) > lineWidth {
+			return block, &armorLineError{line: lineNum, msg: "line is longer than 64 columns"}
+		}
+		if _, err := base64.StdEncoding.DecodeString(padBase64(line)); err != nil {
+			return block, &armorLineError{line: lineNum, msg: "invalid base64"}
+		}
+		body.WriteString(line)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(body.String())
+	if err != nil {
+		return block, fmt.Errorf("armor body: %w", err)
+	}
+	block.data = data
+
+	if checksum == "" {
+		return block, errors.New("armor has no checksum line")
+	}
+	crcBytes, err := base64.StdEncoding.DecodeString(checksum)
+	if err != nil || len(crcBytes) != 3 {
+		return block, errors.New("malformed armor checksum")
+	}
+	want := uint32(crcBytes[0])<<16 | uint32(crcBytes[1])<<8 | uint32(crcBytes[2])
+	if crc24(data) != want {
+		return block, errCRCMismatch
+	}
+	return block, nil
+}
+
+// padBase64 pads a single line so it can be checked on its own.
+// Full lines are a multiple of 4 already, only the last one may be short.
+func padBase64(line string) string {
+	for len(line)%4 != 0 {
+		line += "="
+	}
+	return line
+}
+
+// outputFormat turns raw ciphertext bytes into text and back
+type outputFormat interface {
+	encode(data []byte) string
+	decode(text string) ([]byte, error)
+}
+
+type hexFormat struct{}
+
+func (hexFormat) encode(data []byte) string {
+	return fmt.Sprintf("%x", data)
+}
+
+func (hexFormat) decode(text string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimSpace(text))
+}
+
+type armorFormat struct {
+	headers []header
+}
+
+func (f armorFormat) encode(data []byte) string {
+	return armorEncode(armorBlock{blockType: "PASSLY MESSAGE", headers: f.headers, data: data})
+}
+
+func (f armorFormat) decode(text string) ([]byte, error) {
+	block, err := armorDecode(text)
+	if err != nil {
+		return nil, err
+	}
+	if block.blockType != "PASSLY MESSAGE" {
+		return nil, fmt.Errorf("expected a PASSLY MESSAGE, got %q", block.blockType)
+	}
+	return block.data, nil
+}
+
+// encrypt uses a fresh random IV for every message, and puts it in front of the ciphertext
+// so that the output is all the receiver needs besides the key
+func encrypt(plainText, key string, format outputFormat) string {
+	blockCipher, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+	out := make([]byte, aes.BlockSize+len(plainText))
+	iv := out[:aes.BlockSize]
+	if _, err := rand.Read(iv); err != nil {
+		log.Println(err)
+		return ""
+	}
+	stream := cipher.NewCTR(blockCipher, iv)
+	stream.XORKeyStream(out[aes.BlockSize:], []byte(plainText))
+	return format.encode(out)
+}
+
+func decrypt(cipherText, key string, format outputFormat) (string, error) {
+	blockCipher, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		return "", err
+	}
+	bytes, err := format.decode(cipherText)
+	if err != nil {
+		return "", err
+	}
+	if len(bytes) < aes.BlockSize {
+		return "", errors.New("ciphertext is shorter than the IV")
+	}
+	stream := cipher.NewCTR(blockCipher, bytes[:aes.BlockSize])
+	bytes = bytes[aes.BlockSize:]
+	stream.XORKeyStream(bytes, bytes)
+	return string(bytes), nil
+}
+
+func test(name, armored, key string) {
+	format := armorFormat{}
+	decrypted, err := decrypt(armored, key, format)
+	if err != nil {
+		fmt.Printf("%v: %v\n", name, err)
+		return
+	}
+	fmt.Printf("%v: decrypted %q\n", name, decrypted)
+}
+
+// replaceBodyLine replaces line n (from 0) of the armor body with f(line)
+func replaceBodyLine(armored string, n int, f func(string) string) string {
+	lines := strings.Split(armored, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i+1+n] = f(lines[i+1+n])
+			break
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func main() {
+	const masterKey = "kjhgfdsaqwertyuioplkjhgfdsaqwert"
+
+	fmt.Printf("CRC-24 of \"123456789\": %06X\n", crc24([]byte("123456789")))
+	fmt.Println("========")
+
+	fmt.Printf("Hex output: %v\n", encrypt("k33pThisPasswordSafe", masterKey, hexFormat{}))
+	armored := encrypt("k33pThisPasswordSafe", masterKey, armorFormat{headers: []header{
+		{key: "Version", value: "1"},
+		{key: "Cipher", value: "AES-256-CTR"},
+	}})
+	fmt.Printf("Armored output:\n%v", armored)
+	fmt.Println("========")
+
+	long := encrypt(strings.Repeat("thePasswordOnMyLuggage ", 6), masterKey, armorFormat{})
+	fmt.Printf("A longer message:\n%v", long)
+	fmt.Println("========")
+
+	test("Original", armored, masterKey)
+	test("Pasted with surrounding text", "hey, here's the password:\n\n"+armored+"\nthanks!", masterKey)
+	test("One character changed", replaceBodyLine(armored, 0, func(line string) string {
+		if line[0] == 'A' {
+			return "B" + line[1:]
+		}
+		return "A" + line[1:]
+	}), masterKey)
+	lines := strings.Split(long, "\n")
+	test("A body line deleted", strings.Join(append(lines[:3:3], lines[4:]...), "\n"), masterKey)
+	test("Invalid character", replaceBodyLine(long, 1, func(line string) string {
+		return line[:2] + "!" + line[3:]
+	}), masterKey)
+	test("Cut off", armored[:len(armored)-40], masterKey)
+}
@@ -0,0 +1,373 @@
+/*
+Checksummed Encodings
+Base-8 and hex have no protection against typos. Change one character of a hex key and you get a
+different, perfectly valid key. When people read keys out loud or type them by hand, we want the
+encoding itself to say "this was mistyped" before the key is ever used.
+
+Base58Check (Bitcoin addresses)
+version byte | payload | first 4 bytes of SHA-256(SHA-256(version | payload))
+The whole thing is written in base 58, an alphabet without 0, O, I and l, which are easy to
+confuse. A random typo gets past the 4-byte checksum with a probability of 1 in 4 billion.
+
+Bech32 and Bech32m (BIP-173, BIP-350, SegWit addresses)
+hrp 1 data checksum       e.g. passly1qpzry9x8gf2tvdw0s3jn54khce6mua7l...
+hrp       - a "human readable part" that says what the string is for
+1         - the separator
+data      - base32 with the alphabet "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+checksum  - 6 symbols of a BCH code
+
+The BCH code is much stronger than a truncated hash for the typos people actually make: it is
+guaranteed to detect any 4 wrong characters. A single wrong character can even be located, so we
+can point at it. We only hint at the position and never correct it silently, because "fixing" a
+key that has more than one typo could give you someone else's valid key.
+
+Bech32m is the same thing with a different constant mixed into the checksum. That fixes a weakness
+where inserting or deleting "q" just before a final "p" wasn't detected.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+)
+
+const (
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	bech32Charset  = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+var errBase58Checksum = errors.New("base58check: checksum mismatch")
+
+func base58Encode(src []byte) string {
+	zeroes := 0
+	for zeroes < len(src) && src[zeroes] == 0 {
+		zeroes++
+	}
+	out := []byte{}
+	n := new(big.Int).SetBytes(src)
+	base := big.NewInt(58)
+	mod := new(big.Int)
+	for n.Sign() > 0 {
+		n.DivMod(n, base, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for i := 0; i < zeroes; i++ {
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+func base58Decode(s string) ([]byte, error) {
+	zeroes := 0
+	for zeroes < len(s) && s[zeroes] == base58Alphabet[0] {
+		zeroes++
+	}
+	n := new(big.Int)
+	base := big.NewInt(58)
+	for i := zeroes; i < len(s); i++ {
+		v := strings.IndexByte(base58Alphabet, s[i])
+		if v < 0 {
+			return nil, fmt.Errorf("base58: invalid character %q at position %d", s[i], i)
+		}
+		n.Mul(n, base)
+		n.Add(n, big.NewInt(int64(v)))
+	}
+	return append(make([]byte, zeroes), n.Bytes()...), nil
+}
+
+func doubleSHA256(data []byte) []byte {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+func base58CheckEncode(version byte, payload []byte) string {
+	data := append([]byte{version}, payload...)
+	data = append(data, doubleSHA256(data)[:4]...)
+	return base58Encode(data)
+}
+
+func base58CheckDecode(s string) (byte, []byte, error) {
+	data, err := base58Decode(s)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) < 5 {
+		return 0, nil, errors.New("base58check: too short")
+	}
+	body, checksum := data[:len(data)-4], data[len(data)-4:]
+	if !bytes.Equal(doubleSHA256(body)[:4], checksum) {
+		return 0, nil, errBase58Checksum
+	}
+	return body[0], body[1:], nil
+}
+
+type bech32Variant int
+
+const (
+	bech32 bech32Variant = iota
+	bech32m
+)
+
+func (v bech32Variant) String() string {
+	if v == bech32m {
+		return "bech32m"
+	}
+	return "bech32"
+}
+
+func (v bech32Variant) constant() uint32 {
+	if v == bech32m {
+		return bech32mConst
+	}
+	return bech32Const
+}
+
+// bech32Error is a decoding error. positions holds the index (into the full
+// string) of characters that are wrong, or likely wrong, when they're known.
+type bech32Error struct {
+	msg       string
+	positions []int
+}
+
+func (e *bech32Error) Error() string {
+	if len(e.positions) == 0 {
+		return "bech32: " + e.msg
+	}
+	return fmt.Sprintf("bech32: %s (check position %v)", e.msg, e.positions)
+}
+
+func bech32Polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func hrpExpand(hrp string) []byte {
+	out := []byte{}
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]>>5)
+	}
+	out = append(out, 0)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]&31)
+	}
+	return out
+}
+
+func bech32Checksum(hrp string, data []byte, variant bech32Variant) []byte {
+	values := append(hrpExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	mod := bech32Polymod(values) ^ variant.constant()
+	out := make([]byte, 6)
+	for i := range out {
+		out[i] = byte(mod>>(5*(5-i))) & 31
+	}
+	return out
+}
+
+func bech32Verify(hrp string, data []byte) (bech32Variant, bool) {
+	switch bech32Polymod(append(hrpExpand(hrp), data...)) {
+	case bech32Const:
+		return bech32, true
+	case bech32mConst:
+		return bech32m, true
+	}
+	return 0, false
+}
+
+// convertBits regroups a slice of fromBits-bit values into toBits-bit values
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	acc, nbits := uint(0), uint(0)
+	maxv := uint(1)<<toBits - 1
+	out := []byte{}
+	for _, v := range data {
+		if uint(v)>>fromBits != 0 {
+			return nil, errors.New("invalid data range")
+		}
+		acc = acc<<fromBits | uint(v)
+		nbits += fromBits
+		for nbits >= toBits {
+			nbits -= toBits
+			out = append(out, byte(acc>>nbits&maxv))
+		}
+	}
+	if pad {
+		if nbits > 0 {
+			out = append(out, byte(acc<<(toBits-nbits)&maxv))
+		}
+	} else if nbits >= fromBits || acc<<(toBits-nbits)&maxv != 0 {
+		return nil, errors.New("invalid padding")
+	}
+	return out, nil
+}
+
+func bech32Encode(hrp string, payload []byte, variant bech32Variant) (string, error) {
+	hrp = strings.ToLower(hrp)
+	data, err := convertBits(payload, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	data = append(data, bech32Checksum(hrp, data, variant)...)
+	if len(hrp)+1+len(data) > 90 {
+		return "", errors.New("bech32: result longer than 90 characters")
+	}
+	sb := strings.Builder{}
+	sb.WriteString(hrp + "1")
+	for _, d := range data {
+		sb.WriteByte(bech32Charset[d])
+	}
+	return sb.String(), nil
+}
+
+func bech32Decode(s string) (string, []byte, bech32Variant, error) {
+	if len(s) > 90 {
+		return "", nil, 0, &bech32Error{msg: "longer than 90 characters"}
+	}
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, 0, &bech32Error{msg: "mixed case"}
+	}
+	s = strings.ToLower(s)
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 || sep+7 > len(s) {
+		return "", nil, 0, &bech32Error{msg: "missing separator or too short"}
+	}
+	hrp := s[:sep]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, 0, &bech32Error{msg: "invalid character in hrp", positions: []int{i}}
+		}
+	}
+
+	data := []byte{}
+	bad := []int{}
+	for i := sep + 1; i < len(s); i++ {
+		v := strings.IndexByte(bech32Charset, s[i])
+		if v < 0 {
+			bad = append(bad, i)
+			continue
+		}
+		data = append(data, byte(v))
+	}
+	if len(bad) > 0 {
+		return "", nil, 0, &bech32Error{msg: "invalid character", positions: bad}
+	}
+
+	variant, ok := bech32Verify(hrp, data)
+	if !ok {
+		return "", nil, 0, &bech32Error{msg: "checksum mismatch", positions: locateError(hrp, data, sep+1)}
+	}
+
+	payload, err := convertBits(data[:len(data)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, 0, &bech32Error{msg: err.Error()}
+	}
+	return hrp, payload, variant, nil
+}
+
+// locateError looks for a single substituted character that would make the
+// checksum valid again. The BCH code guarantees such a position is unique.
+// It returns nil when more than one character is wrong.
+func locateError(hrp string, data []byte, offset int) []int {
+	candidate := make([]byte, len(data))
+	for i := range data {
+		copy(candidate, data)
+		for v := byte(0); v < 32; v++ {
+			if v == data[i] {
+				continue
+			}
+			candidate[i] = v
+			if _, ok := bech32Verify(hrp, candidate); ok {
+				return []int{offset + i}
+			}
+		}
+	}
+	return nil
+}
+
+func testBech32(s string) {
+	hrp, payload, variant, err := bech32Decode(s)
+	if err != nil {
+		fmt.Printf("%v: %v\n", s, err)
+		return
+	}
+	fmt.Printf("%v: valid %v, hrp %q, payload %x\n", s, variant, hrp, payload)
+}
+
+func testBase58Check(s string) {
+	version, payload, err := base58CheckDecode(s)
+	if err != nil {
+		fmt.Printf("%v: %v\n", s, err)
+		return
+	}
+	fmt.Printf("%v: valid, version %v, payload %x\n", s, version, payload)
+}
+
+// typo swaps the character at i for the next one in alphabet
+func typo(s string, i int, alphabet string) string {
+	b := []byte(s)
+	b[i] = alphabet[(strings.IndexByte(alphabet, b[i])+1)%len(alphabet)]
+	return string(b)
+}
+
+func main() {
+	// test vectors from BIP-173, BIP-350 and Bitcoin
+	testBase58Check("1111111111111111111114oLvT2")
+	testBech32("A12UEL5L")
+	testBech32("abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw")
+	testBech32("A1LQFN3A")
+	testBech32("abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx")
+	testBech32("A12UEL5l")
+	fmt.Println("========")
+
+	// share the ID of a public key by hand
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fingerprint := sha256.Sum256(pub)
+	keyID := fingerprint[:20]
+	fmt.Printf("Key ID: %x\n", keyID)
+
+	b58 := base58CheckEncode(0x50, keyID)
+	fmt.Printf("Base58Check: %v\n", b58)
+	testBase58Check(b58)
+	testBase58Check(typo(b58, 10, base58Alphabet))
+	testBase58Check(b58[:len(b58)-1])
+	fmt.Println("========")
+
+	b32, err := bech32Encode("passly", keyID, bech32m)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Bech32m: %v\n", b32)
+	testBech32(b32)
+	testBech32(strings.ToUpper(b32))
+	testBech32(typo(b32, 12, bech32Charset))
+	testBech32(typo(typo(b32, 12, bech32Charset), 20, bech32Charset))
+	testBech32(b32[:15] + "b" + b32[16:])
+	testBech32(b32[:10] + strings.ToUpper(b32[10:]))
+}
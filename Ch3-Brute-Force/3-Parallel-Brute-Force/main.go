@@ -0,0 +1,282 @@
+/*
+Parallel Brute Force
+findKey tries all 2^24 keys one after another on a single goroutine. It says nothing while it runs,
+and there's no way to stop it short of killing the program. Real cracking tools do three things
+differently:
+
+1. Split the keyspace across a pool of workers, one per CPU core.
+2. Report progress and the current rate (keys per second), so you know how long it will take.
+3. Stop early, either because a worker found the key or because the caller cancelled the search
+   through a context (a timeout, Ctrl-C, ...).
+
+The engine doesn't know anything about XOR or keys. It counts from 0 to keyspace-1 and asks a
+predicate "is this the one?" for every number. That makes it easy to reuse for any integer
+keyspace, and to measure how the time to crack grows with the size of the key.
+
+Workers don't get one fixed slice of the keyspace each. Instead they grab small chunks from a
+shared counter, so a slow worker never holds everybody else up, and the search stops within one
+chunk of the key being found.
+
+Every extra bit of key doubles the keyspace, and so doubles the worst-case time. Adding CPU cores
+only divides it by a constant.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const chunkSize = 1 << 14
+
+var errKeyNotFound = errors.New("key not found")
+
+type progress struct {
+	tried   uint64
+	total   uint64
+	elapsed time.Duration
+}
+
+// rate is the number of keys tried per second so far
+func (p progress) rate() float64 {
+	if p.elapsed <= 0 {
+		return 0
+	}
+	return float64(p.tried) / p.elapsed.Seconds()
+}
+
+func (p progress) String() string {
+	percent := 100.0
+	if p.total > 0 {
+		percent = 100 * float64(p.tried) / float64(p.total)
+	}
+	return fmt.Sprintf("%5.1f%% (%v of %v keys) in %v, %.2f M keys/s",
+		percent, p.tried, p.total, p.elapsed.Round(time.Microsecond), p.rate()/1e6)
+}
+
+type engine struct {
+	workers       int
+	progressEvery time.Duration
+	onProgress    func(progress)
+}
+
+func newEngine() *engine {
+	return &engine{
+		workers:       runtime.NumCPU(),
+		progressEvery: time.Second,
+	}
+}
+
+// search calls test for every key in [0, keyspace) until it returns true.
+// It returns errKeyNotFound if no key matches, or ctx.Err() if ctx is cancelled first.
+// test is called from several goroutines at once, so it must be safe for concurrent use.
+// onProgress is never called after search returns.
+func (e *engine) search(ctx context.Context, keyspace uint64, test func(key uint64) bool) (uint64, progress, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	var next, tried atomic.Uint64
+	var found atomic.Bool
+	var result uint64
+
+	snapshot := func() progress {
+		return progress{tried: tried.Load(), total: keyspace, elapsed: time.Since(start)}
+	}
+
+	// claim hands out the next chunk of keys. A compare-and-swap loop instead of next.Add, so
+	// the counter never goes past keyspace and can't wrap around when keyspace is close to 2^64.
+	claim := func() (lo, hi uint64, ok bool) {
+		for {
+			lo = next.Load()
+			if lo >= keyspace {
+				return 0, 0, false
+			}
+			hi = keyspace
+			if keyspace-lo > chunkSize {
+				hi = lo + chunkSize
+			}
+			if next.CompareAndSwap(lo, hi) {
+				return lo, hi, true
+			}
+		}
+	}
+
+	stop := make(chan struct{})
+	progressDone := sync.WaitGroup{}
+	if e.onProgress != nil && e.progressEvery > 0 {
+		ticker := time.NewTicker(e.progressEvery)
+		progressDone.Add(1)
+		go func() {
+			defer progressDone.Done()
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					e.onProgress(snapshot())
+				}
+			}
+		}()
+	}
+
+	wg := sync.WaitGroup{}
+	workers := e.workers
+	if workers < 1 {
+		workers = 1
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				lo, hi, ok := claim()
+				if !ok {
+					return
+				}
+				for key := lo; key < hi; key++ {
+					if test(key) {
+						if found.CompareAndSwap(false, true) {
+							result = key
+						}
+						tried.Add(key - lo + 1)
+						cancel()
+						return
+					}
+				}
+				tried.Add(hi - lo)
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	progressDone.Wait()
+
+	p := snapshot()
+	if found.Load() {
+		return result, p, nil
+	}
+	// cancel() above only runs when a key is found, so this is the caller's context
+	if err := ctx.Err(); err != nil {
+		return 0, p, err
+	}
+	return 0, p, errKeyNotFound
+}
+
+func findKey(encrypted []byte, decrypted string) ([]byte, error) {
+	e := newEngine()
+	key, _, err := e.search(context.Background(), 1<<24, func(i uint64) bool {
+		return bytes.Equal([]byte(decrypted), crypt(encrypted, keyBytes(i, 3)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keyBytes(key, 3), nil
+}
+
+func crypt(dat, key []byte) []byte {
+	final := []byte{}
+	for i, d := range dat {
+		final = append(final, d^key[i])
+	}
+	return final
+}
+
+// keyBytes is intToBytes without the bytes.Buffer: the little endian
+// bytes of num, truncated to n bytes
+func keyBytes(num uint64, n int) []byte {
+	bs := make([]byte, n)
+	for i := range bs {
+		bs[i] = byte(num >> (8 * i))
+	}
+	return bs
+}
+
+func test(encrypted []byte, decrypted string) {
+	fmt.Printf("Encrypted: %x, decrypted: %s\n", []byte(encrypted), decrypted)
+	fmt.Println("Starting brute force search...")
+	key, err := findKey(encrypted, decrypted)
+	if err != nil {
+		fmt.Println("Error: ", err)
+		return
+	}
+	fmt.Printf("Key found: %x\n", key)
+	fmt.Println("========")
+}
+
+// timeToCrack searches an n-bit keyspace for a key hidden at the very end
+// (the worst case), and returns how long that took
+func timeToCrack(bits int) progress {
+	secret := uint64(1)<<bits - 1
+	plaintext := []byte("passly!!")
+	encrypted := make([]byte, len(plaintext))
+	for i := range plaintext {
+		encrypted[i] = plaintext[i] ^ byte(secret>>(8*(i%8)))
+	}
+
+	e := newEngine()
+	_, p, _ := e.search(context.Background(), uint64(1)<<bits, func(k uint64) bool {
+		// compare in place rather than allocating a new slice for every key
+		for i := range encrypted {
+			if encrypted[i]^byte(k>>(8*(i%8))) != plaintext[i] {
+				return false
+			}
+		}
+		return true
+	})
+	return p
+}
+
+func formatDuration(seconds float64) string {
+	const year = 365 * 24 * 3600
+	switch {
+	case seconds < 60:
+		return fmt.Sprintf("%.2f seconds", seconds)
+	case seconds < 3600:
+		return fmt.Sprintf("%.1f minutes", seconds/60)
+	case seconds < 3600*24:
+		return fmt.Sprintf("%.1f hours", seconds/3600)
+	case seconds < year:
+		return fmt.Sprintf("%.1f days", seconds/3600/24)
+	}
+	return fmt.Sprintf("%.3g years", seconds/year)
+}
+
+func main() {
+	fmt.Printf("Using %v workers\n", newEngine().workers)
+	test([]byte{0x1b, 0x2c, 0x3d}, "yes")
+	test([]byte{0x2a, 0xff, 0xea}, "car")
+	test([]byte{0x7d, 0x31, 0x32}, "she")
+
+	fmt.Println("Worst case time to crack by key size:")
+	var rate float64
+	for bits := 16; bits <= 26; bits += 2 {
+		p := timeToCrack(bits)
+		rate = p.rate()
+		fmt.Printf("%3v bits: %v\n", bits, p)
+	}
+	fmt.Printf("Extrapolated at %.2f M keys/s:\n", rate/1e6)
+	for _, bits := range []int{32, 36, 40, 56, 64, 128} {
+		fmt.Printf("%3v bits: %v\n", bits, formatDuration(math.Pow(2, float64(bits))/rate))
+	}
+	fmt.Println("========")
+
+	fmt.Println("Searching a 48-bit keyspace with a 3 second timeout...")
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	e := newEngine()
+	e.onProgress = func(p progress) {
+		fmt.Printf("  progress: %v\n", p)
+	}
+	_, p, err := e.search(ctx, 1<<48, func(k uint64) bool { return false })
+	fmt.Printf("Stopped: %v after %v\n", err, p)
+}
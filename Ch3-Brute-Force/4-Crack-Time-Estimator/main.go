@@ -0,0 +1,205 @@
+/*
+Crack Time Estimator
+alphabetSize tells us how many characters n bits can represent. Turn it around and we can ask the
+question every password policy is really about: how long would it take to brute force this?
+
+1. Character classes
+A password that uses lowercase letters and digits was picked from an alphabet of 26 + 10 = 36
+characters. An attacker who knows (or guesses) that will only try those 36.
+
+lowercase  26    abc...z
+uppercase  26    ABC...Z
+digits     10    0-9
+symbols    33    !"#$%&'()*+,-./:;<=>?@[\]^_`{|}~ and space
+other     100    anything outside ASCII (a rough guess, there's no fixed number)
+
+2. Keyspace and entropy
+keyspace = alphabet ^ length
+entropy  = log2(keyspace) = length * log2(alphabet) bits
+
+A random key of n bits has an alphabet of 2 and a length of n: alphabetSize(n) keys, n bits.
+
+3. Crack time
+On average the attacker finds the password halfway through the keyspace:
+expected time = keyspace / 2 / guesses per second
+
+How many guesses per second depends entirely on how the password is stored and reached:
+
+online, throttled   - guessing through a login form that allows 100 tries an hour
+online, unthrottled - the same form with no rate limit, maybe 10 tries a second
+fast hash           - a stolen database of unsalted SHA-256 hashes, one high-end GPU does about
+                      20 billion a second. Salting stops rainbow tables, but not this.
+bcrypt              - about 180,000 a second at cost 5 on the same GPU. Every +1 of cost halves it.
+
+These numbers are for pure brute force. A real attacker tries dictionary words and leaked
+passwords first, so a human-chosen password is usually far weaker than its keyspace suggests.
+
+Usage
+go run . [-cost 12] PASSWORD...
+go run . -bits 128
+Running with no arguments runs the demo.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+type charClass struct {
+	name string
+	size int
+	test func(r rune) bool
+}
+
+var charClasses = []charClass{
+	{"lowercase", 26, func(r rune) bool { return r >= 'a' && r <= 'z' }},
+	{"uppercase", 26, func(r rune) bool { return r >= 'A' && r <= 'Z' }},
+	{"digits", 10, func(r rune) bool { return r >= '0' && r <= '9' }},
+	{"symbols", 33, func(r rune) bool {
+		return r < unicode.MaxASCII && (unicode.IsPunct(r) || unicode.IsSymbol(r) || r == ' ')
+	}},
+	{"other", 100, func(r rune) bool { return r > unicode.MaxASCII }},
+}
+
+type attacker struct {
+	name             string
+	guessesPerSecond float64
+}
+
+const (
+	sha256GPURate      = 20e9
+	bcryptCost5GPURate = 180e3
+)
+
+func attackers(bcryptCost int) []attacker {
+	return []attacker{
+		{"online, throttled", 100.0 / 3600},
+		{"online, unthrottled", 10},
+		{"fast hash (SHA-256)", sha256GPURate},
+		{fmt.Sprintf("bcrypt, cost %d", bcryptCost), bcryptRate(bcryptCost)},
+	}
+}
+
+// bcryptRate doubles the work for every step of cost above 5
+func bcryptRate(cost int) float64 {
+	return bcryptCost5GPURate / math.Pow(2, float64(cost-5))
+}
+
+func alphabetSize(numBits int) float64 {
+	return math.Pow(2, float64(numBits))
+}
+
+type estimate struct {
+	classes  []string
+	alphabet int
+	length   int
+	// entropy is log2 of the keyspace. It's kept as a log because keyspaces
+	// quickly get too big for a float64.
+	entropy float64
+}
+
+func (e estimate) keyspace() float64 {
+	return math.Pow(2, e.entropy)
+}
+
+// expectedSeconds is the average time to find the password: half the keyspace
+func (e estimate) expectedSeconds(a attacker) float64 {
+	return math.Pow(2, e.entropy-1) / a.guessesPerSecond
+}
+
+func estimatePassword(password string) estimate {
+	e := estimate{}
+	used := make([]bool, len(charClasses))
+	for _, r := range password {
+		e.length++
+		for i, c := range charClasses {
+			if c.test(r) {
+				used[i] = true
+				break
+			}
+		}
+	}
+	for i, c := range charClasses {
+		if used[i] {
+			e.classes = append(e.classes, c.name)
+			e.alphabet += c.size
+		}
+	}
+	if e.alphabet > 0 {
+		e.entropy = float64(e.length) * math.Log2(float64(e.alphabet))
+	}
+	return e
+}
+
+// estimateKey is a random key of numBits bits
+func estimateKey(numBits int) estimate {
+	return estimate{classes: []string{"binary"}, alphabet: 2, length: numBits, entropy: float64(numBits)}
+}
+
+func formatDuration(seconds float64) string {
+	const (
+		minute = 60
+		hour   = 60 * minute
+		day    = 24 * hour
+		year   = 365 * day
+	)
+	switch {
+	case seconds < 1:
+		return "instantly"
+	case seconds < minute:
+		return fmt.Sprintf("%.0f seconds", seconds)
+	case seconds < hour:
+		return fmt.Sprintf("%.0f minutes", seconds/minute)
+	case seconds < day:
+		return fmt.Sprintf("%.0f hours", seconds/hour)
+	case seconds < year:
+		return fmt.Sprintf("%.0f days", seconds/day)
+	case seconds < 1e6*year:
+		return fmt.Sprintf("%.0f years", seconds/year)
+	}
+	return fmt.Sprintf("%.2g years", seconds/year)
+}
+
+func report(label string, e estimate, bcryptCost int) string {
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "%v\n", label)
+	fmt.Fprintf(&sb, "  classes:  %v\n", strings.Join(e.classes, ", "))
+	fmt.Fprintf(&sb, "  keyspace: %v^%v = %.3g\n", e.alphabet, e.length, e.keyspace())
+	fmt.Fprintf(&sb, "  entropy:  %.1f bits\n", e.entropy)
+	for _, a := range attackers(bcryptCost) {
+		fmt.Fprintf(&sb, "  %-20v %v\n", a.name+":", formatDuration(e.expectedSeconds(a)))
+	}
+	return sb.String()
+}
+
+func main() {
+	cost := flag.Int("cost", 12, "bcrypt cost factor")
+	bits := flag.Int("bits", 0, "estimate a random key of this many bits instead of a password")
+	flag.Parse()
+
+	if *bits > 0 {
+		fmt.Print(report(fmt.Sprintf("%v-bit random key", *bits), estimateKey(*bits), *cost))
+		return
+	}
+	if flag.NArg() > 0 {
+		for _, password := range flag.Args() {
+			fmt.Print(report(fmt.Sprintf("Password %q", password), estimatePassword(password), *cost))
+		}
+		return
+	}
+
+	for _, password := range []string{"12345", "pizza_the_HUt", "k33pThisPasswordSafe", "correct horse battery staple", "Tr0ub4dor&3"} {
+		fmt.Print(report(fmt.Sprintf("Password %q", password), estimatePassword(password), *cost))
+		fmt.Println("========")
+	}
+	for _, n := range []int{24, 56, 128} {
+		fmt.Printf("alphabetSize(%v) = %.3g\n", n, alphabetSize(n))
+		fmt.Print(report(fmt.Sprintf("%v-bit random key", n), estimateKey(n), *cost))
+		fmt.Println("========")
+	}
+}
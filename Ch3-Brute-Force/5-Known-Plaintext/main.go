@@ -0,0 +1,221 @@
+/*
+Known Plaintext
+findKey tries all 2^24 keys until one of them turns the ciphertext into the plaintext we were given.
+But look at what crypt does: ciphertext = plaintext XOR key. XOR is its own inverse, so
+
+key = ciphertext XOR plaintext
+
+If we know the plaintext, we don't need to search at all. One XOR gives us the key, no matter how
+long it is. This is called a known-plaintext attack, and it's why XOR with a reusable key is
+hopeless: attackers often know part of a message (a file header, "Dear Sir", "password: ").
+
+Knowing just a part is enough if the key repeats. With a key of length 6, byte i of the message
+is XORed with key[i % 6]. A known fragment at any offset reveals key[offset % 6], key[(offset+1) % 6]
+and so on. A fragment of 6 or more bytes reveals the whole key, which then decrypts everything.
+
+We don't even need to know the key length. XOR the fragment with the ciphertext to get a piece of
+the keystream, then look for the smallest period p where every keystream byte matches the byte p
+positions further on. A wrong period fails that check almost immediately.
+
+If the key is longer than what we know, we still recover the key bytes we can, decrypt the
+positions they cover, and report exactly which key bytes are still missing.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// fragment is a piece of plaintext we know, and where it sits in the message
+type fragment struct {
+	offset int
+	text   []byte
+}
+
+type recovery struct {
+	period   int
+	key      []byte
+	known    []bool // known[i] reports whether key[i] was recovered
+	inferred bool   // period was inferred rather than given
+}
+
+func (r recovery) unknownKeyBytes() []int {
+	missing := []int{}
+	for i, ok := range r.known {
+		if !ok {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
+// keystream XORs every fragment with the ciphertext, giving the key byte used at each position
+func keystream(ciphertext []byte, fragments []fragment) (map[int]byte, error) {
+	ks := map[int]byte{}
+	for _, f := range fragments {
+		if f.offset < 0 || f.offset+len(f.text) > len(ciphertext) {
+			return nil, fmt.Errorf("fragment at offset %d doesn't fit in the ciphertext", f.offset)
+		}
+		for i, p := range f.text {
+			pos := f.offset + i
+			k := ciphertext[pos] ^ p
+			if prev, ok := ks[pos]; ok && prev != k {
+				return nil, fmt.Errorf("fragments disagree at offset %d", pos)
+			}
+			ks[pos] = k
+		}
+	}
+	return ks, nil
+}
+
+// inferPeriod returns the smallest period the keystream is consistent with.
+// A period only counts if at least one pair of positions actually repeats,
+// otherwise every period longer than the fragments would trivially "fit".
+func inferPeriod(ks map[int]byte, maxPeriod int) (int, bool) {
+	for p := 1; p <= maxPeriod; p++ {
+		seen := map[int]byte{}
+		consistent, repeats := true, false
+		for pos, k := range ks {
+			if prev, ok := seen[pos%p]; ok {
+				repeats = true
+				if prev != k {
+					consistent = false
+					break
+				}
+			}
+			seen[pos%p] = k
+		}
+		if consistent && repeats {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+// recoverKey recovers as much of a repeating XOR key as the fragments allow.
+// A period of 0 means infer it. If no period can be inferred, the key is
+// treated as being as long as the ciphertext, like crypt in Crack an Insecure Key.
+func recoverKey(ciphertext []byte, fragments []fragment, period int) (recovery, error) {
+	if len(fragments) == 0 {
+		return recovery{}, errors.New("need at least one known fragment")
+	}
+	ks, err := keystream(ciphertext, fragments)
+	if err != nil {
+		return recovery{}, err
+	}
+
+	r := recovery{period: period}
+	if period <= 0 {
+		p, ok := inferPeriod(ks, len(ciphertext)/2)
+		if !ok {
+			p = len(ciphertext)
+		}
+		r.period, r.inferred = p, ok
+	}
+
+	r.key = make([]byte, r.period)
+	r.known = make([]bool, r.period)
+	for pos, k := range ks {
+		i := pos % r.period
+		if r.known[i] && r.key[i] != k {
+			return recovery{}, fmt.Errorf("known plaintext doesn't fit a key of period %d", r.period)
+		}
+		r.key[i], r.known[i] = k, true
+	}
+	return r, nil
+}
+
+// decryptPartial decrypts every byte whose key byte is known, and shows the rest as '_'
+func decryptPartial(ciphertext []byte, r recovery) string {
+	sb := strings.Builder{}
+	for i, c := range ciphertext {
+		if !r.known[i%r.period] {
+			sb.WriteByte('_')
+			continue
+		}
+		sb.WriteByte(c ^ r.key[i%r.period])
+	}
+	return sb.String()
+}
+
+func crypt(dat, key []byte) []byte {
+	final := []byte{}
+	for i, d := range dat {
+		final = append(final, d^key[i%len(key)])
+	}
+	return final
+}
+
+// formatRanges prints sorted indexes as "1-6, 9"
+func formatRanges(indexes []int) string {
+	parts := []string{}
+	for i := 0; i < len(indexes); {
+		j := i
+		for j+1 < len(indexes) && indexes[j+1] == indexes[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, fmt.Sprint(indexes[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%v-%v", indexes[i], indexes[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
+
+func test(name string, ciphertext []byte, fragments []fragment, period int) {
+	fmt.Println(name)
+	for _, f := range fragments {
+		fmt.Printf("  known: %q at offset %v\n", f.text, f.offset)
+	}
+	r, err := recoverKey(ciphertext, fragments, period)
+	if err != nil {
+		fmt.Println("  Error:", err)
+		fmt.Println("========")
+		return
+	}
+	how := "given"
+	if r.inferred {
+		how = "inferred"
+	} else if period <= 0 {
+		how = "no repetition found, assuming a one-time key"
+	}
+	fmt.Printf("  period: %v (%v)\n", r.period, how)
+	fmt.Printf("  key: %x\n", r.key)
+	missing := r.unknownKeyBytes()
+	sort.Ints(missing)
+	if len(missing) > 0 {
+		fmt.Printf("  unknown key bytes: %v of %v (%v)\n", len(missing), r.period, formatRanges(missing))
+	}
+	fmt.Printf("  plaintext: %q\n", decryptPartial(ciphertext, r))
+	fmt.Println("========")
+}
+
+func main() {
+	// the messages from Crack an Insecure Key, no brute force needed
+	test("yes", []byte{0x1b, 0x2c, 0x3d}, []fragment{{0, []byte("yes")}}, 3)
+	test("car", []byte{0x2a, 0xff, 0xea}, []fragment{{0, []byte("car")}}, 3)
+
+	msg := []byte("Hi team, the new vault password: k33pThisPasswordSafe. Please don't share it with anyone!")
+
+	test("Repeating 6-byte key, period inferred",
+		crypt(msg, []byte("PASSLY")),
+		[]fragment{{17, []byte("vault password: ")}}, 0)
+
+	test("Repeating 16-byte key, one short fragment",
+		crypt(msg, []byte("thisIsMySecretKe")),
+		[]fragment{{23, []byte("password: ")}}, 16)
+
+	test("Repeating 16-byte key, two fragments",
+		crypt(msg, []byte("thisIsMySecretKe")),
+		[]fragment{{0, []byte("Hi team, ")}, {23, []byte("password: ")}}, 0)
+
+	test("Key as long as the message",
+		crypt(msg, []byte("c5f149783abf22a96e9a7bb999c5f149783abf22a96e9a7bb999c5f149783abf22a96e9a7bb999c5f149783abf2")),
+		[]fragment{{0, []byte("Hi team, ")}}, 0)
+}
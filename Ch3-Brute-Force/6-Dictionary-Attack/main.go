@@ -0,0 +1,559 @@
+/*
+Dictionary Attack
+Crack an Insecure Key tried every possible key. Against passwords that's a waste of time: people
+don't pick random strings, they pick words, names and dates, and then change them in predictable
+ways. Offline crackers like hashcat and John the Ripper have three main modes:
+
+Wordlist    - try every word in a list of common or leaked passwords.
+Rules       - mangle every word the way people do: "sunshine" -> "Sunshine", "5un5h1n3",
+              "sunshine1", "Sunshine2024". A rule is a short program, e.g. "c$1" means
+              "capitalize, then append 1".
+Mask        - brute force, but only over the shape people use: "?u?l?l?l?d?d" is one uppercase
+              letter, three lowercase letters and two digits. That's 26*26^3*10^2 = 45 million
+              guesses instead of 62^6 = 56 billion.
+
+Mask charsets: ?l lowercase, ?u uppercase, ?d digits, ?s symbols, ?a all of them.
+Rule ops: : nothing, l lowercase, u uppercase, c capitalize, t toggle case, r reverse,
+          L leetspeak, $X append X, ^X prepend X, sXY replace X with Y.
+
+The targets are the hashes from Ch14-KDFs/2-Salts: sha256(password || salt). Salting stops
+precomputed rainbow tables, and it also means every user's hash has to be attacked separately.
+Without a salt, one SHA-256 of a guess is checked against every user at once.
+
+A salt doesn't make the hash any slower though. Compare the guesses per second at the end:
+SHA-256 does millions per second per core, bcrypt does a handful.
+
+Usage
+go run . [-wordlist words.txt] [-workers 8]
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/bits"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const batchSize = 1024
+
+const (
+	algoSHA256       = "sha256"
+	algoSaltedSHA256 = "sha256+salt"
+	algoBcrypt       = "bcrypt"
+)
+
+// a short list of very common passwords, a real attack would use rockyou.txt or similar
+var defaultWordlist = []string{
+	"password", "123456", "qwerty", "letmein", "monkey", "dragon", "football", "baseball",
+	"sunshine", "iloveyou", "princess", "admin", "welcome", "shadow", "master", "superman",
+	"michael", "jennifer", "hunter", "trustno1", "batman", "starwars", "freedom", "whatever",
+	"pizza", "secret", "summer", "winter", "passly", "cookie", "chocolate", "soccer",
+}
+
+type target struct {
+	name   string
+	algo   string
+	salt   []byte
+	digest []byte // the sha256 digest, or the full bcrypt hash
+}
+
+// hashPassword is the salted hash from Ch14-KDFs/2-Salts
+func hashPassword(password, salt []byte) []byte {
+	h := sha256.New()
+	h.Write(password)
+	h.Write(salt)
+	return h.Sum(nil)
+}
+
+func newTarget(name, algo, password string) (target, error) {
+	t := target{name: name, algo: algo}
+	switch algo {
+	case algoSHA256:
+		t.digest = hashPassword([]byte(password), nil)
+	case algoSaltedSHA256:
+		t.salt = make([]byte, 16)
+		if _, err := rand.Read(t.salt); err != nil {
+			return t, err
+		}
+		t.digest = hashPassword([]byte(password), t.salt)
+	case algoBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			return t, err
+		}
+		t.digest = hash
+	default:
+		return t, fmt.Errorf("unknown algorithm %q", algo)
+	}
+	return t, nil
+}
+
+// checker tests guesses against a set of targets. Unsalted hashes are looked
+// up in a map, so one hash of the guess covers all of them. Accounts with the
+// same password have the same unsalted hash, so each digest keeps a list.
+type checker struct {
+	unsalted map[string][]target
+	salted   []target
+	total    int
+
+	mu    sync.Mutex
+	found map[string]string
+	done  atomic.Int64
+}
+
+func newChecker(targets []target) *checker {
+	c := &checker{unsalted: map[string][]target{}, found: map[string]string{}, total: len(targets)}
+	for _, t := range targets {
+		if t.algo == algoSHA256 {
+			c.unsalted[string(t.digest)] = append(c.unsalted[string(t.digest)], t)
+			continue
+		}
+		c.salted = append(c.salted, t)
+	}
+	return c
+}
+
+func (c *checker) record(t target, guess string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.found[t.name]; !ok {
+		c.found[t.name] = guess
+		c.done.Add(1)
+	}
+}
+
+func (c *checker) isFound(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.found[name]
+	return ok
+}
+
+func (c *checker) check(guess string) {
+	if len(c.unsalted) > 0 {
+		for _, t := range c.unsalted[string(hashPassword([]byte(guess), nil))] {
+			c.record(t, guess)
+		}
+	}
+	for _, t := range c.salted {
+		switch t.algo {
+		case algoSaltedSHA256:
+			if string(hashPassword([]byte(guess), t.salt)) == string(t.digest) {
+				c.record(t, guess)
+			}
+		case algoBcrypt:
+			// bcrypt is slow enough that skipping cracked targets matters
+			if !c.isFound(t.name) && bcrypt.CompareHashAndPassword(t.digest, []byte(guess)) == nil {
+				c.record(t, guess)
+			}
+		}
+	}
+}
+
+func (c *checker) allFound() bool {
+	return int(c.done.Load()) == c.total
+}
+
+// generator sends batches of guesses until it runs out or ctx is cancelled
+type generator func(ctx context.Context, out chan<- []string)
+
+type stats struct {
+	guesses uint64
+	elapsed time.Duration
+}
+
+func (s stats) rate() float64 {
+	return float64(s.guesses) / s.elapsed.Seconds()
+}
+
+// crack spreads the guesses over a pool of workers and stops when every
+// target is cracked, the guesses run out, or ctx is cancelled.
+// workers below 1 are treated as 1, with no workers nothing would read the guesses.
+func crack(ctx context.Context, targets []target, gen generator, workers int) (map[string]string, stats) {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c := newChecker(targets)
+	batches := make(chan []string, workers)
+	var guesses atomic.Uint64
+	start := time.Now()
+
+	go func() {
+		defer close(batches)
+		gen(ctx, batches)
+	}()
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				for _, guess := range batch {
+					if ctx.Err() != nil {
+						break
+					}
+					c.check(guess)
+					guesses.Add(1)
+				}
+				if c.allFound() {
+					cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return c.found, stats{guesses: guesses.Load(), elapsed: time.Since(start)}
+}
+
+// send sends a batch, reporting false if ctx was cancelled first
+func send(ctx context.Context, out chan<- []string, batch []string) bool {
+	select {
+	case out <- batch:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func wordlistGenerator(words []string, rules []string) (generator, error) {
+	parsed := make([][]ruleOp, len(rules))
+	for i, r := range rules {
+		ops, err := parseRule(r)
+		if err != nil {
+			return nil, err
+		}
+		parsed[i] = ops
+	}
+	return func(ctx context.Context, out chan<- []string) {
+		batch := make([]string, 0, batchSize)
+		for _, word := range words {
+			for _, ops := range parsed {
+				batch = append(batch, applyRule(word, ops))
+				if len(batch) == batchSize {
+					if !send(ctx, out, batch) {
+						return
+					}
+					batch = make([]string, 0, batchSize)
+				}
+			}
+		}
+		if len(batch) > 0 {
+			send(ctx, out, batch)
+		}
+	}, nil
+}
+
+var maskCharsets = map[byte]string{
+	'l': "abcdefghijklmnopqrstuvwxyz",
+	'u': "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	'd': "0123456789",
+	's': " !\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~",
+}
+
+func init() {
+	maskCharsets['a'] = maskCharsets['l'] + maskCharsets['u'] + maskCharsets['d'] + maskCharsets['s']
+}
+
+// parseMask turns "?u?l?d!" into one charset per position. Anything not
+// after a ? is a literal, and ?? is a literal question mark.
+func parseMask(mask string) ([]string, error) {
+	positions := []string{}
+	for i := 0; i < len(mask); i++ {
+		if mask[i] != '?' {
+			positions = append(positions, mask[i:i+1])
+			continue
+		}
+		if i+1 == len(mask) {
+			return nil, errors.New("mask ends with a lone ?")
+		}
+		i++
+		if mask[i] == '?' {
+			positions = append(positions, "?")
+			continue
+		}
+		charset, ok := maskCharsets[mask[i]]
+		if !ok {
+			return nil, fmt.Errorf("unknown mask charset ?%c", mask[i])
+		}
+		positions = append(positions, charset)
+	}
+	return positions, nil
+}
+
+func maskGenerator(mask string) (generator, uint64, error) {
+	positions, err := parseMask(mask)
+	if err != nil {
+		return nil, 0, err
+	}
+	total := uint64(1)
+	for _, p := range positions {
+		hi, lo := bits.Mul64(total, uint64(len(p)))
+		if hi != 0 {
+			return nil, 0, fmt.Errorf("mask %q has more than 2^64 candidates", mask)
+		}
+		total = lo
+	}
+	return func(ctx context.Context, out chan<- []string) {
+		// counter is an odometer over the positions, the last one turns fastest
+		counter := make([]int, len(positions))
+		guess := make([]byte, len(positions))
+		batch := make([]string, 0, batchSize)
+		for n := uint64(0); n < total; n++ {
+			for i, p := range positions {
+				guess[i] = p[counter[i]]
+			}
+			batch = append(batch, string(guess))
+			if len(batch) == batchSize {
+				if !send(ctx, out, batch) {
+					return
+				}
+				batch = make([]string, 0, batchSize)
+			}
+			for i := len(counter) - 1; i >= 0; i-- {
+				counter[i]++
+				if counter[i] < len(positions[i]) {
+					break
+				}
+				counter[i] = 0
+			}
+		}
+		if len(batch) > 0 {
+			send(ctx, out, batch)
+		}
+	}, total, nil
+}
+
+type ruleOp struct {
+	op   byte
+	args [2]byte
+}
+
+func parseRule(rule string) ([]ruleOp, error) {
+	ops := []ruleOp{}
+	for i := 0; i < len(rule); i++ {
+		op := ruleOp{op: rule[i]}
+		nargs := 0
+		switch rule[i] {
+		case ':', 'l', 'u', 'c', 't', 'r', 'L':
+		case '$', '^':
+			nargs = 1
+		case 's':
+			nargs = 2
+		default:
+			return nil, fmt.Errorf("rule %q: unknown op %q", rule, rule[i])
+		}
+		if i+nargs >= len(rule) && nargs > 0 {
+			return nil, fmt.Errorf("rule %q: op %q is missing arguments", rule, rule[i])
+		}
+		for a := 0; a < nargs; a++ {
+			i++
+			op.args[a] = rule[i]
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+var leet = strings.NewReplacer("a", "4", "e", "3", "i", "1", "o", "0", "s", "5", "t", "7",
+	"A", "4", "E", "3", "I", "1", "O", "0", "S", "5", "T", "7")
+
+func applyRule(word string, ops []ruleOp) string {
+	for _, op := range ops {
+		switch op.op {
+		case 'l':
+			word = strings.ToLower(word)
+		case 'u':
+			word = strings.ToUpper(word)
+		case 'c':
+			if word != "" {
+				word = strings.ToUpper(word[:1]) + strings.ToLower(word[1:])
+			}
+		case 't':
+			b := []byte(word)
+			for i, ch := range b {
+				switch {
+				case ch >= 'a' && ch <= 'z':
+					b[i] = ch - 'a' + 'A'
+				case ch >= 'A' && ch <= 'Z':
+					b[i] = ch - 'A' + 'a'
+				}
+			}
+			word = string(b)
+		case 'r':
+			b := []byte(word)
+			for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+				b[i], b[j] = b[j], b[i]
+			}
+			word = string(b)
+		case 'L':
+			word = leet.Replace(word)
+		case '$':
+			word += string(op.args[0])
+		case '^':
+			word = string(op.args[0]) + word
+		case 's':
+			word = strings.ReplaceAll(word, string(op.args[0]), string(op.args[1]))
+		}
+	}
+	return word
+}
+
+// defaultRules tries a few case and leetspeak variants of every word,
+// each on its own and followed by one or two digits
+func defaultRules() []string {
+	rules := []string{}
+	for _, base := range []string{":", "c", "u", "t", "L", "cL"} {
+		rules = append(rules, base)
+		for d := '0'; d <= '9'; d++ {
+			rules = append(rules, fmt.Sprintf("%s$%c", base, d))
+		}
+		for d := 0; d < 100; d++ {
+			rules = append(rules, fmt.Sprintf("%s$%c$%c", base, '0'+d/10, '0'+d%10))
+		}
+	}
+	return rules
+}
+
+func readWordlist(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	words := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if w := strings.TrimSpace(scanner.Text()); w != "" {
+			words = append(words, w)
+		}
+	}
+	return words, scanner.Err()
+}
+
+func report(name string, targets []target, found map[string]string, s stats) {
+	fmt.Printf("%v: %v guesses in %v (%.0f guesses/s)\n", name, s.guesses, s.elapsed.Round(time.Millisecond), s.rate())
+	for _, t := range targets {
+		if password, ok := found[t.name]; ok {
+			fmt.Printf("  %-6v %-12v cracked: %q\n", t.name, t.algo, password)
+		} else {
+			fmt.Printf("  %-6v %-12v not found\n", t.name, t.algo)
+		}
+	}
+	fmt.Println("========")
+}
+
+// benchmark measures guesses per second against one target that never matches
+func benchmark(algo string, workers int, d time.Duration) (stats, error) {
+	t, err := newTarget("bench", algo, "this is not in the mask")
+	if err != nil {
+		return stats{}, err
+	}
+	gen, _, err := maskGenerator("?a?a?a?a?a?a")
+	if err != nil {
+		return stats{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	_, s := crack(ctx, []target{t}, gen, workers)
+	return s, nil
+}
+
+func mustTarget(name, algo, password string) target {
+	t, err := newTarget(name, algo, password)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return t
+}
+
+func main() {
+	wordlistPath := flag.String("wordlist", "", "file with one word per line (default: a small built-in list)")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of worker goroutines")
+	flag.Parse()
+
+	words := defaultWordlist
+	if *wordlistPath != "" {
+		var err error
+		words, err = readWordlist(*wordlistPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	targets := []target{
+		mustTarget("alice", algoSHA256, "sunshine"),
+		mustTarget("bob", algoSHA256, "Dragon7"),
+		// same password as alice, and without a salt the same hash
+		mustTarget("grace", algoSHA256, "sunshine"),
+		mustTarget("carol", algoSaltedSHA256, "p455w0rd"),
+		mustTarget("dave", algoSaltedSHA256, "Monkey42"),
+		mustTarget("erin", algoBcrypt, "password"),
+		mustTarget("frank", algoSaltedSHA256, "Kat99"),
+	}
+
+	gen, err := wordlistGenerator(words, []string{":"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	found, s := crack(context.Background(), targets, gen, *workers)
+	report("Wordlist", targets, found, s)
+
+	rules := defaultRules()
+	gen, err = wordlistGenerator(words, rules)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// bcrypt would make this run 1000 times slower, leave it out
+	found, s = crack(context.Background(), targets[:5], gen, *workers)
+	report(fmt.Sprintf("Wordlist + %v rules", len(rules)), targets[:5], found, s)
+
+	gen, total, err := maskGenerator("?u?l?l?d?d")
+	if err != nil {
+		log.Fatal(err)
+	}
+	found, s = crack(context.Background(), targets[6:], gen, *workers)
+	report(fmt.Sprintf("Mask ?u?l?l?d?d (%v candidates)", total), targets[6:], found, s)
+
+	// 95^10 candidates don't fit in a uint64
+	if _, _, err := maskGenerator(strings.Repeat("?a", 10)); err != nil {
+		fmt.Println("Mask error:", err)
+		fmt.Println("========")
+	}
+
+	fmt.Printf("Guesses per second with %v workers:\n", *workers)
+	var bcryptRate float64
+	for _, algo := range []string{algoSHA256, algoSaltedSHA256, algoBcrypt} {
+		s, err := benchmark(algo, *workers, 2*time.Second)
+		if err != nil {
+			log.Fatal(err)
+		}
+		name := algo
+		if algo == algoBcrypt {
+			name = fmt.Sprintf("bcrypt, cost %v", bcrypt.MinCost)
+			bcryptRate = s.rate()
+		}
+		fmt.Printf("  %-16v %12.0f\n", name, s.rate())
+	}
+	// every +1 of cost doubles the work
+	cost := bcrypt.DefaultCost
+	fmt.Printf("  %-16v %12.1f (estimated)\n", fmt.Sprintf("bcrypt, cost %v", cost), bcryptRate/float64(int(1)<<(cost-bcrypt.MinCost)))
+}
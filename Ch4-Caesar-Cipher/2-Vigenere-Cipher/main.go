@@ -0,0 +1,419 @@
+/*
+Vigenère Cipher
+The Caesar cipher shifts every letter by the same amount, so frequency analysis breaks it at once.
+The Vigenère cipher uses a keyword instead, and shifts each letter by the next letter of the key:
+
+plaintext   attackatdawn
+key         lemonlemonle
+ciphertext  lxfopvefrnhr
+
+a in the key is a shift of 0, b is 1, ... z is 25. It's just one Caesar cipher per key letter, taking
+turns. The most common plaintext letter now turns into several different ciphertext letters, which
+flattens the frequencies. For 300 years it was called "le chiffre indéchiffrable".
+
+Breaking it
+Once we know the key length n, letters 0, n, 2n, ... were all shifted by the same key letter. That
+column is a plain Caesar cipher, and we already know how to break those. So the real problem is
+finding n. There are two classic ways:
+
+Kasiski examination - common words like "the" will sometimes line up with the same part of the
+key, and then encrypt to the same ciphertext. The distance between two such repeats is a multiple
+of the key length, so n divides most of the distances.
+
+Index of coincidence - the chance that two letters picked at random from a text are the same. For
+English it's about 0.066, for uniformly random letters 1/26 = 0.038. If we guess the right n, every
+column is a Caesar-shifted piece of English and has an IoC close to 0.066. With a wrong n the
+columns mix several shifts and look more random.
+
+Multiples of n also give English-looking columns, and also give a key that's the real key repeated.
+breakVigenere shortlists the best lengths from both methods, solves each, keeps the decryption that
+looks most like English, and then shortens the key to its smallest repeating unit.
+
+Only letters are encrypted, and only letters move the key forward. Case and everything else is
+kept as it is.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+const (
+	englishIoC = 0.0667
+	randomIoC  = 1.0 / 26
+)
+
+// getOffsetChar is copied unchanged from the Caesar cipher lesson (Ch4-Caesar-Cipher/main.go),
+// every lesson is its own program and can't import another one
+func getOffsetChar(c rune, offset int) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz"
+	const upperAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	for _, a := range []string{alphabet, upperAlphabet} {
+		for i, curr := range a {
+			if curr == c {
+				modI := (i + offset) % len(a)
+				if modI < 0 {
+					modI += len(a)
+				}
+				return string(a[modI])
+			}
+		}
+	}
+	return string(c)
+}
+
+func isLetter(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// keyShifts turns a key like "lemon" into the shifts 11, 4, 12, 14, 13
+func keyShifts(key string) ([]int, error) {
+	if key == "" {
+		return nil, errors.New("key is empty")
+	}
+	shifts := []int{}
+	for _, c := range strings.ToLower(key) {
+		if c < 'a' || c > 'z' {
+			return nil, fmt.Errorf("key contains %q, only letters are allowed", c)
+		}
+		shifts = append(shifts, int(c-'a'))
+	}
+	return shifts, nil
+}
+
+func crypt(text string, shifts []int, sign int) string {
+	sb := strings.Builder{}
+	i := 0
+	for _, c := range text {
+		if !isLetter(c) {
+			sb.WriteRune(c)
+			continue
+		}
+		sb.WriteString(getOffsetChar(c, sign*shifts[i%len(shifts)]))
+		i++
+	}
+	return sb.String()
+}
+
+func encrypt(plaintext, key string) (string, error) {
+	shifts, err := keyShifts(key)
+	if err != nil {
+		return "", err
+	}
+	return crypt(plaintext, shifts, 1), nil
+}
+
+func decrypt(ciphertext, key string) (string, error) {
+	shifts, err := keyShifts(key)
+	if err != nil {
+		return "", err
+	}
+	return crypt(ciphertext, shifts, -1), nil
+}
+
+// letters returns only the letters of text, lowercased, as 0-25
+func letters(text string) []int {
+	out := []int{}
+	for _, c := range strings.ToLower(text) {
+		if c >= 'a' && c <= 'z' {
+			out = append(out, int(c-'a'))
+		}
+	}
+	return out
+}
+
+// columns splits the letters into n columns: every n-th letter, starting at 0, 1, ... n-1
+func columns(ls []int, n int) [][]int {
+	cols := make([][]int, n)
+	for i, l := range ls {
+		cols[i%n] = append(cols[i%n], l)
+	}
+	return cols
+}
+
+func indexOfCoincidence(ls []int) float64 {
+	if len(ls) < 2 {
+		return 0
+	}
+	counts := [26]int{}
+	for _, l := range ls {
+		counts[l]++
+	}
+	sum := 0
+	for _, c := range counts {
+		sum += c * (c - 1)
+	}
+	return float64(sum) / float64(len(ls)*(len(ls)-1))
+}
+
+// averageIoC is the mean index of coincidence of the columns for key length n
+func averageIoC(ls []int, n int) float64 {
+	total := 0.0
+	for _, col := range columns(ls, n) {
+		total += indexOfCoincidence(col)
+	}
+	return total / float64(n)
+}
+
+// kasiski finds repeated trigrams and returns, for every length 2..maxLen,
+// how much more often than chance it divides the distance between repeats
+func kasiski(ls []int, maxLen int) map[int]float64 {
+	const seqLen = 3
+	last := map[[seqLen]int]int{}
+	distances := []int{}
+	for i := 0; i+seqLen <= len(ls); i++ {
+		seq := [seqLen]int{}
+		copy(seq[:], ls[i:i+seqLen])
+		if prev, ok := last[seq]; ok {
+			distances = append(distances, i-prev)
+		}
+		last[seq] = i
+	}
+
+	scores := map[int]float64{}
+	if len(distances) == 0 {
+		return scores
+	}
+	for n := 2; n <= maxLen; n++ {
+		divisible := 0
+		for _, d := range distances {
+			if d%n == 0 {
+				divisible++
+			}
+		}
+		// a random distance is divisible by n 1 time in n
+		scores[n] = float64(divisible)/float64(len(distances)) - 1/float64(n)
+	}
+	return scores
+}
+
+// englishFrequencies is how often each letter a-z appears in English text
+var englishFrequencies = [26]float64{
+	0.08167, 0.01492, 0.02782, 0.04253, 0.12702, 0.02228, 0.02015, 0.06094, 0.06966,
+	0.00153, 0.00772, 0.04025, 0.02406, 0.06749, 0.07507, 0.01929, 0.00095, 0.05987,
+	0.06327, 0.09056, 0.02758, 0.00978, 0.02360, 0.00150, 0.01974, 0.00074,
+}
+
+// chiSquared measures how far the letters are from English, after shifting them back by shift.
+// Lower is more English-like.
+func chiSquared(ls []int, shift int) float64 {
+	counts := [26]int{}
+	for _, l := range ls {
+		counts[(l-shift+26)%26]++
+	}
+	score := 0.0
+	for i, f := range englishFrequencies {
+		expected := f * float64(len(ls))
+		diff := float64(counts[i]) - expected
+		score += diff * diff / expected
+	}
+	return score
+}
+
+// solveColumn breaks one column as a Caesar cipher
+func solveColumn(col []int) int {
+	best, bestScore := 0, math.Inf(1)
+	for shift := 0; shift < 26; shift++ {
+		if score := chiSquared(col, shift); score < bestScore {
+			best, bestScore = shift, score
+		}
+	}
+	return best
+}
+
+// shortestPeriod returns the smallest prefix of key that repeats to make all of key
+func shortestPeriod(key []int) []int {
+	for p := 1; p < len(key); p++ {
+		if len(key)%p != 0 {
+			continue
+		}
+		repeats := true
+		for i := p; i < len(key); i++ {
+			if key[i] != key[i-p] {
+				repeats = false
+				break
+			}
+		}
+		if repeats {
+			return key[:p]
+		}
+	}
+	return key
+}
+
+type keyLength struct {
+	n       int
+	ioc     float64
+	kasiski float64
+}
+
+// scoreKeyLengths scores every key length from 1 to maxLen with both methods
+func scoreKeyLengths(ls []int, maxLen int) []keyLength {
+	k := kasiski(ls, maxLen)
+	lengths := []keyLength{}
+	for n := 1; n <= maxLen; n++ {
+		lengths = append(lengths, keyLength{n: n, ioc: averageIoC(ls, n), kasiski: k[n]})
+	}
+	return lengths
+}
+
+// shortlist picks the best few lengths by IoC and the best few by Kasiski
+func shortlist(lengths []keyLength, size int) []int {
+	byIoC := append([]keyLength{}, lengths...)
+	sort.SliceStable(byIoC, func(i, j int) bool {
+		return math.Abs(byIoC[i].ioc-englishIoC) < math.Abs(byIoC[j].ioc-englishIoC)
+	})
+	byKasiski := append([]keyLength{}, lengths...)
+	sort.SliceStable(byKasiski, func(i, j int) bool {
+		return byKasiski[i].kasiski > byKasiski[j].kasiski
+	})
+
+	seen := map[int]bool{}
+	out := []int{}
+	for i := 0; i < size && i < len(lengths); i++ {
+		for _, n := range []int{byIoC[i].n, byKasiski[i].n} {
+			if !seen[n] {
+				seen[n] = true
+				out = append(out, n)
+			}
+		}
+	}
+	return out
+}
+
+func breakVigenere(ciphertext string, maxLen int) (string, error) {
+	ls := letters(ciphertext)
+	if len(ls) < 2*maxLen {
+		return "", fmt.Errorf("need at least %d letters to try keys up to %d long, got %d", 2*maxLen, maxLen, len(ls))
+	}
+
+	var bestKey []int
+	bestScore := math.Inf(1)
+	for _, n := range shortlist(scoreKeyLengths(ls, maxLen), 3) {
+		key := make([]int, n)
+		for i, col := range columns(ls, n) {
+			key[i] = solveColumn(col)
+		}
+		// score the whole decryption, so keys of different lengths are comparable
+		decrypted := make([]int, len(ls))
+		for i, l := range ls {
+			decrypted[i] = (l - key[i%n] + 26) % 26
+		}
+		if score := chiSquared(decrypted, 0); score < bestScore {
+			bestKey, bestScore = key, score
+		}
+	}
+
+	sb := strings.Builder{}
+	for _, shift := range shortestPeriod(bestKey) {
+		sb.WriteByte(byte('a' + shift))
+	}
+	return sb.String(), nil
+}
+
+func randomKey(r *rand.Rand, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte('a' + r.Intn(26))
+	}
+	return string(b)
+}
+
+func test(plaintext, key string) {
+	fmt.Printf("Encrypting %q with key %q\n", plaintext, key)
+	encrypted, err := encrypt(plaintext, key)
+	if err != nil {
+		fmt.Println("Error:", err)
+		fmt.Println("========")
+		return
+	}
+	fmt.Printf("Encrypted text: %v\n", encrypted)
+	decrypted, err := decrypt(encrypted, key)
+	if err != nil {
+		fmt.Println("Error:", err)
+		fmt.Println("========")
+		return
+	}
+	fmt.Printf("Decrypted text: %v\n", decrypted)
+	fmt.Println("========")
+}
+
+const gettysburg = `Four score and seven years ago our fathers brought forth on this continent, a new nation,
+conceived in Liberty, and dedicated to the proposition that all men are created equal.
+Now we are engaged in a great civil war, testing whether that nation, or any nation so conceived and
+so dedicated, can long endure. We are met on a great battle-field of that war. We have come to
+dedicate a portion of that field, as a final resting place for those who here gave their lives that
+that nation might live. It is altogether fitting and proper that we should do this.
+But, in a larger sense, we can not dedicate -- we can not consecrate -- we can not hallow -- this
+ground. The brave men, living and dead, who struggled here, have consecrated it, far above our poor
+power to add or detract. The world will little note, nor long remember what we say here, but it can
+never forget what they did here. It is for us the living, rather, to be dedicated here to the
+unfinished work which they who fought here have thus far so nobly advanced. It is rather for us to
+be here dedicated to the great task remaining before us -- that from these honored dead we take
+increased devotion to that cause for which they gave the last full measure of devotion -- that we
+here highly resolve that these dead shall not have died in vain -- that this nation, under God,
+shall have a new birth of freedom -- and that government of the people, by the people, for the
+people, shall not perish from the earth.`
+
+func main() {
+	test("attackatdawn", "lemon")
+	test("Attack at dawn!", "LEMON")
+	test("Attack at dawn!", "lem0n")
+
+	// show how the two methods see a key of length 7
+	ciphertext, err := encrypt(gettysburg, "passlyx")
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println("Key length estimates for a key of length 7:")
+	fmt.Printf("%4v %8v %8v\n", "n", "IoC", "Kasiski")
+	for _, kl := range scoreKeyLengths(letters(ciphertext), 14) {
+		fmt.Printf("%4v %8.4f %8.3f\n", kl.n, kl.ioc, kl.kasiski)
+	}
+	fmt.Printf("English IoC is %.4f, random text %.4f\n", englishIoC, randomIoC)
+	fmt.Println("========")
+
+	r := rand.New(rand.NewSource(1))
+	broken, total := 0, 0
+	for n := 3; n <= 20; n++ {
+		key := randomKey(r, n)
+		ciphertext, err := encrypt(gettysburg, key)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		found, err := breakVigenere(ciphertext, 20)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		result := "wrong"
+		if found == key {
+			result = "broken"
+			broken++
+		}
+		total++
+		fmt.Printf("key length %2v: %-20v found %-20v %v\n", n, key, found, result)
+	}
+	fmt.Printf("Broke %v of %v keys\n", broken, total)
+	fmt.Println("========")
+
+	found, err := breakVigenere(ciphertext, 20)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	plaintext, err := decrypt(ciphertext, found)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("Key: %v\n%v\n", found, plaintext[:200])
+}
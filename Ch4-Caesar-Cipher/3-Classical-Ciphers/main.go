@@ -0,0 +1,659 @@
+/*
+Classical Ciphers
+Caesar and Vigenère aren't the only ciphers from before computers. They all fall into two families:
+
+Substitution - every letter is replaced by another one, the positions stay the same.
+Caesar      shift every letter by the key:                    E(x) = x + k mod 26
+Affine      multiply, then shift:                             E(x) = a*x + b mod 26
+            a needs an inverse mod 26 to decrypt, so it can't share a factor with 26
+Atbash      reverse the alphabet, a <-> z, b <-> y, ...       E(x) = 25 - x, no key at all
+Vigenère    a Caesar shift per letter, taken in turn from a keyword
+Playfair    encrypt pairs of letters using a 5x5 square built from a keyword (J is merged into I)
+Hill        encrypt blocks of n letters by multiplying them with an n x n key matrix mod 26
+
+Transposition - the letters stay the same, the positions are shuffled.
+Rail Fence  write the text in a zigzag over n rails, then read it off rail by rail
+Columnar    write the text in rows under a keyword, then read it off column by column, in the
+            alphabetical order of the keyword's letters
+
+Caesar, Affine, Atbash and Vigenère keep case and anything that isn't a letter, like the Caesar cipher in
+this chapter. Playfair and Hill work on pairs and blocks of letters, so they drop everything else
+and pad with X. A ciphertext for them that isn't made of whole pairs or blocks can't be decrypted,
+it was cut short or typed wrong. The transpositions shuffle every character, spaces included.
+
+Every cipher implements the same interface, and is created by parsing and validating a key string,
+so the CLI can pick any of them by name.
+
+Usage
+go run . -cipher playfair -key "playfair example" encrypt "Hide the gold in the tree stump"
+go run . -cipher affine -key 5,8 decrypt "IHHWVC SWFRCP"
+go run . -list
+Running with no arguments runs the demo.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type classicalCipher interface {
+	encrypt(plaintext string) string
+	decrypt(ciphertext string) (string, error)
+}
+
+type cipherInfo struct {
+	keyHelp string
+	parse   func(key string) (classicalCipher, error)
+}
+
+var ciphers = map[string]cipherInfo{
+	"caesar":    {"a shift, e.g. 3", parseCaesar},
+	"affine":    {"a,b with a coprime to 26, e.g. 5,8", parseAffine},
+	"atbash":    {"no key", parseAtbash},
+	"vigenere":  {"a keyword, e.g. lemon", parseVigenere},
+	"playfair":  {"a keyword, e.g. \"playfair example\"", parsePlayfair},
+	"hill":      {"n*n letters (\"GYBNQKURP\") or numbers (\"3 3 2 5\"), invertible mod 26", parseHill},
+	"railfence": {"the number of rails, e.g. 3", parseRailFence},
+	"columnar":  {"a keyword, e.g. ZEBRAS", parseColumnar},
+}
+
+// newCipher looks up a cipher by name and parses its key
+func newCipher(name, key string) (classicalCipher, error) {
+	info, ok := ciphers[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown cipher %q", name)
+	}
+	c, err := info.parse(key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return c, nil
+}
+
+// mod is the mathematical modulo, always in [0, m)
+func mod(x, m int) int {
+	x %= m
+	if x < 0 {
+		x += m
+	}
+	return x
+}
+
+// modInverse finds x with a*x = 1 mod m
+func modInverse(a, m int) (int, bool) {
+	a = mod(a, m)
+	for x := 1; x < m; x++ {
+		if a*x%m == 1 {
+			return x, true
+		}
+	}
+	return 0, false
+}
+
+// mapLetters applies f to every letter as 0-25, keeping case and passing everything else through
+func mapLetters(text string, f func(x int) int) string {
+	sb := strings.Builder{}
+	for _, c := range text {
+		switch {
+		case c >= 'a' && c <= 'z':
+			sb.WriteByte(byte('a' + f(int(c-'a'))))
+		case c >= 'A' && c <= 'Z':
+			sb.WriteByte(byte('A' + f(int(c-'A'))))
+		default:
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+// upperLetters keeps only the letters of text, uppercased
+func upperLetters(text string) string {
+	sb := strings.Builder{}
+	for _, c := range strings.ToUpper(text) {
+		if c >= 'A' && c <= 'Z' {
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+type caesar struct {
+	shift int
+}
+
+func parseCaesar(key string) (classicalCipher, error) {
+	shift, err := strconv.Atoi(strings.TrimSpace(key))
+	if err != nil {
+		return nil, errors.New("key must be a number")
+	}
+	return caesar{mod(shift, 26)}, nil
+}
+
+func (c caesar) encrypt(plaintext string) string {
+	return mapLetters(plaintext, func(x int) int { return mod(x+c.shift, 26) })
+}
+
+func (c caesar) decrypt(ciphertext string) (string, error) {
+	return mapLetters(ciphertext, func(x int) int { return mod(x-c.shift, 26) }), nil
+}
+
+type affine struct {
+	a, b, aInverse int
+}
+
+func parseAffine(key string) (classicalCipher, error) {
+	aStr, bStr, ok := strings.Cut(key, ",")
+	if !ok {
+		return nil, errors.New("key must be two numbers, a,b")
+	}
+	a, err := strconv.Atoi(strings.TrimSpace(aStr))
+	if err != nil {
+		return nil, fmt.Errorf("a: %w", err)
+	}
+	b, err := strconv.Atoi(strings.TrimSpace(bStr))
+	if err != nil {
+		return nil, fmt.Errorf("b: %w", err)
+	}
+	aInverse, ok := modInverse(a, 26)
+	if !ok {
+		return nil, fmt.Errorf("a = %d shares a factor with 26, so it can't be decrypted", a)
+	}
+	return affine{mod(a, 26), mod(b, 26), aInverse}, nil
+}
+
+func (c affine) encrypt(plaintext string) string {
+	return mapLetters(plaintext, func(x int) int { return mod(c.a*x+c.b, 26) })
+}
+
+func (c affine) decrypt(ciphertext string) (string, error) {
+	return mapLetters(ciphertext, func(x int) int { return mod(c.aInverse*(x-c.b), 26) }), nil
+}
+
+type atbash struct{}
+
+func parseAtbash(key string) (classicalCipher, error) {
+	if strings.TrimSpace(key) != "" {
+		return nil, errors.New("takes no key")
+	}
+	return atbash{}, nil
+}
+
+func (atbash) encrypt(plaintext string) string {
+	return mapLetters(plaintext, func(x int) int { return 25 - x })
+}
+
+// decrypt is the same as encrypt, Atbash is its own inverse
+func (atbash) decrypt(ciphertext string) (string, error) {
+	return mapLetters(ciphertext, func(x int) int { return 25 - x }), nil
+}
+
+type vigenere struct {
+	shifts []int
+}
+
+func parseVigenere(key string) (classicalCipher, error) {
+	if upperLetters(key) != strings.ToUpper(key) || key == "" {
+		return nil, errors.New("key must be one or more letters")
+	}
+	shifts := []int{}
+	for _, c := range upperLetters(key) {
+		shifts = append(shifts, int(c-'A'))
+	}
+	return vigenere{shifts}, nil
+}
+
+// crypt shifts every letter by the next key letter, times sign. Only letters move the key forward.
+func (c vigenere) crypt(text string, sign int) string {
+	i := 0
+	return mapLetters(text, func(x int) int {
+		x = mod(x+sign*c.shifts[i%len(c.shifts)], 26)
+		i++
+		return x
+	})
+}
+
+func (c vigenere) encrypt(plaintext string) string {
+	return c.crypt(plaintext, 1)
+}
+
+func (c vigenere) decrypt(ciphertext string) (string, error) {
+	return c.crypt(ciphertext, -1), nil
+}
+
+type playfair struct {
+	square [25]byte
+	pos    [26]int // index into square of every letter, J shares I's
+}
+
+func parsePlayfair(key string) (classicalCipher, error) {
+	letters := upperLetters(key)
+	if letters == "" {
+		return nil, errors.New("key must contain at least one letter")
+	}
+	p := playfair{}
+	used := [26]bool{}
+	n := 0
+	for _, c := range strings.ReplaceAll(letters, "J", "I") + "ABCDEFGHIKLMNOPQRSTUVWXYZ" {
+		if used[c-'A'] {
+			continue
+		}
+		used[c-'A'] = true
+		p.square[n] = byte(c)
+		p.pos[c-'A'] = n
+		n++
+	}
+	p.pos['J'-'A'] = p.pos['I'-'A']
+	return p, nil
+}
+
+// digraphs splits the text into pairs, putting an X between double letters
+// and at the end if needed. X itself is split with a Q.
+func digraphs(text string) []string {
+	letters := strings.ReplaceAll(upperLetters(text), "J", "I")
+	pairs := []string{}
+	for i := 0; i < len(letters); {
+		a := letters[i]
+		if i+1 == len(letters) || letters[i+1] == a {
+			filler := byte('X')
+			if a == 'X' {
+				filler = 'Q'
+			}
+			pairs = append(pairs, string([]byte{a, filler}))
+			i++
+			continue
+		}
+		pairs = append(pairs, letters[i:i+2])
+		i += 2
+	}
+	return pairs
+}
+
+// crypt applies the Playfair rules, moving right/down to encrypt and left/up to decrypt:
+// same row - take the letters next to each one in the row
+// same column - take the letters next to each one in the column
+// otherwise - take the other two corners of the rectangle, staying in the same row
+func (p playfair) crypt(pairs []string, dir int) string {
+	sb := strings.Builder{}
+	for _, pair := range pairs {
+		a, b := p.pos[pair[0]-'A'], p.pos[pair[1]-'A']
+		ra, ca, rb, cb := a/5, a%5, b/5, b%5
+		switch {
+		case ra == rb:
+			ca, cb = mod(ca+dir, 5), mod(cb+dir, 5)
+		case ca == cb:
+			ra, rb = mod(ra+dir, 5), mod(rb+dir, 5)
+		default:
+			ca, cb = cb, ca
+		}
+		sb.WriteByte(p.square[ra*5+ca])
+		sb.WriteByte(p.square[rb*5+cb])
+	}
+	return sb.String()
+}
+
+func (p playfair) encrypt(plaintext string) string {
+	return p.crypt(digraphs(plaintext), 1)
+}
+
+// decrypt leaves the filler letters in, there's no way to tell them from real ones
+func (p playfair) decrypt(ciphertext string) (string, error) {
+	letters := strings.ReplaceAll(upperLetters(ciphertext), "J", "I")
+	if len(letters)%2 != 0 {
+		return "", fmt.Errorf("ciphertext has %d letters, it must be pairs", len(letters))
+	}
+	pairs := []string{}
+	for i := 0; i < len(letters); i += 2 {
+		pairs = append(pairs, letters[i:i+2])
+	}
+	return p.crypt(pairs, -1), nil
+}
+
+type hill struct {
+	n       int
+	key     [][]int
+	inverse [][]int
+}
+
+func parseHill(key string) (classicalCipher, error) {
+	values := []int{}
+	// "GYB NQK URP" is a letter key too, so only look at what's between the spaces
+	compact := strings.Join(strings.Fields(key), "")
+	if len(upperLetters(compact)) != len(compact) {
+		for _, f := range strings.Fields(key) {
+			v, err := strconv.Atoi(f)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a number", f)
+			}
+			values = append(values, mod(v, 26))
+		}
+	} else {
+		for _, c := range upperLetters(key) {
+			values = append(values, int(c-'A'))
+		}
+	}
+
+	n := 1
+	for n*n < len(values) {
+		n++
+	}
+	if n < 2 || n*n != len(values) {
+		return nil, fmt.Errorf("key has %d values, it needs a square number of at least 4", len(values))
+	}
+	m := make([][]int, n)
+	for i := range m {
+		m[i] = values[i*n : (i+1)*n]
+	}
+	inverse, err := matrixInverse(m)
+	if err != nil {
+		return nil, err
+	}
+	return hill{n: n, key: m, inverse: inverse}, nil
+}
+
+// minor is m without row r and column c
+func minor(m [][]int, r, c int) [][]int {
+	out := [][]int{}
+	for i, row := range m {
+		if i == r {
+			continue
+		}
+		newRow := []int{}
+		for j, v := range row {
+			if j != c {
+				newRow = append(newRow, v)
+			}
+		}
+		out = append(out, newRow)
+	}
+	return out
+}
+
+// determinant by cofactor expansion, fine for the small matrices used here
+func determinant(m [][]int) int {
+	if len(m) == 1 {
+		return m[0][0]
+	}
+	det := 0
+	sign := 1
+	for c := range m[0] {
+		det += sign * m[0][c] * determinant(minor(m, 0, c))
+		sign = -sign
+	}
+	return det
+}
+
+// matrixInverse inverts m mod 26 as det^-1 * adjugate(m)
+func matrixInverse(m [][]int) ([][]int, error) {
+	det := mod(determinant(m), 26)
+	detInverse, ok := modInverse(det, 26)
+	if !ok {
+		return nil, fmt.Errorf("key matrix has determinant %d, which shares a factor with 26, so it can't be decrypted", det)
+	}
+	n := len(m)
+	inverse := make([][]int, n)
+	for i := range inverse {
+		inverse[i] = make([]int, n)
+	}
+	for r := 0; r < n; r++ {
+		for c := 0; c < n; c++ {
+			cofactor := determinant(minor(m, r, c))
+			if (r+c)%2 == 1 {
+				cofactor = -cofactor
+			}
+			// the adjugate is the transpose of the cofactor matrix
+			inverse[c][r] = mod(detInverse*cofactor, 26)
+		}
+	}
+	return inverse, nil
+}
+
+func (h hill) crypt(text string, m [][]int) string {
+	letters := upperLetters(text)
+	for len(letters)%h.n != 0 {
+		letters += "X"
+	}
+	out := make([]byte, len(letters))
+	for block := 0; block < len(letters); block += h.n {
+		for r := 0; r < h.n; r++ {
+			sum := 0
+			for c := 0; c < h.n; c++ {
+				sum += m[r][c] * int(letters[block+c]-'A')
+			}
+			out[block+r] = byte('A' + mod(sum, 26))
+		}
+	}
+	return string(out)
+}
+
+func (h hill) encrypt(plaintext string) string {
+	return h.crypt(plaintext, h.key)
+}
+
+func (h hill) decrypt(ciphertext string) (string, error) {
+	if n := len(upperLetters(ciphertext)); n%h.n != 0 {
+		return "", fmt.Errorf("ciphertext has %d letters, it must be blocks of %d", n, h.n)
+	}
+	return h.crypt(ciphertext, h.inverse), nil
+}
+
+type railFence struct {
+	rails int
+}
+
+func parseRailFence(key string) (classicalCipher, error) {
+	rails, err := strconv.Atoi(strings.TrimSpace(key))
+	if err != nil {
+		return nil, errors.New("key must be a number")
+	}
+	if rails < 2 {
+		return nil, errors.New("need at least 2 rails")
+	}
+	return railFence{rails}, nil
+}
+
+// railOf returns the rail every position of an n character text lands on
+func (r railFence) railOf(n int) []int {
+	out := make([]int, n)
+	rail, dir := 0, 1
+	for i := range out {
+		out[i] = rail
+		if rail == 0 {
+			dir = 1
+		} else if rail == r.rails-1 {
+			dir = -1
+		}
+		rail += dir
+	}
+	return out
+}
+
+// order lists the positions of an n character text in the order they're read off the fence
+func (r railFence) order(n int) []int {
+	rails := r.railOf(n)
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return rails[order[i]] < rails[order[j]]
+	})
+	return order
+}
+
+func (r railFence) encrypt(plaintext string) string {
+	return transpose([]rune(plaintext), r.order(len([]rune(plaintext))))
+}
+
+func (r railFence) decrypt(ciphertext string) (string, error) {
+	return untranspose([]rune(ciphertext), r.order(len([]rune(ciphertext)))), nil
+}
+
+type columnar struct {
+	keyword string
+}
+
+func parseColumnar(key string) (classicalCipher, error) {
+	keyword := upperLetters(key)
+	if len(keyword) < 2 {
+		return nil, errors.New("keyword must have at least 2 letters")
+	}
+	return columnar{keyword}, nil
+}
+
+// order lists the positions of an n character text in the order they're read off:
+// column by column in alphabetical order of the keyword, repeated letters left to right.
+// The last row may be short, there's no padding.
+func (c columnar) order(n int) []int {
+	cols := make([]int, len(c.keyword))
+	for i := range cols {
+		cols[i] = i
+	}
+	sort.SliceStable(cols, func(i, j int) bool {
+		return c.keyword[cols[i]] < c.keyword[cols[j]]
+	})
+	order := []int{}
+	for _, col := range cols {
+		for pos := col; pos < n; pos += len(c.keyword) {
+			order = append(order, pos)
+		}
+	}
+	return order
+}
+
+func (c columnar) encrypt(plaintext string) string {
+	return transpose([]rune(plaintext), c.order(len([]rune(plaintext))))
+}
+
+func (c columnar) decrypt(ciphertext string) (string, error) {
+	return untranspose([]rune(ciphertext), c.order(len([]rune(ciphertext)))), nil
+}
+
+// transpose reads text in the given order
+func transpose(text []rune, order []int) string {
+	out := make([]rune, len(text))
+	for i, pos := range order {
+		out[i] = text[pos]
+	}
+	return string(out)
+}
+
+// untranspose puts every character back where transpose took it from
+func untranspose(text []rune, order []int) string {
+	out := make([]rune, len(text))
+	for i, pos := range order {
+		out[pos] = text[i]
+	}
+	return string(out)
+}
+
+func listCiphers() {
+	names := []string{}
+	for name := range ciphers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%-10v key: %v\n", name, ciphers[name].keyHelp)
+	}
+}
+
+func test(name, key, plaintext string) {
+	fmt.Printf("%v, key %q\n", name, key)
+	c, err := newCipher(name, key)
+	if err != nil {
+		fmt.Println("Error:", err)
+		fmt.Println("========")
+		return
+	}
+	encrypted := c.encrypt(plaintext)
+	fmt.Printf("Plaintext:  %v\n", plaintext)
+	fmt.Printf("Encrypted:  %v\n", encrypted)
+	decrypted, err := c.decrypt(encrypted)
+	if err != nil {
+		fmt.Println("Error:", err)
+		fmt.Println("========")
+		return
+	}
+	fmt.Printf("Decrypted:  %v\n", decrypted)
+	fmt.Println("========")
+}
+
+// testDecrypt decrypts a ciphertext that didn't come from encrypt, like one typed in by hand
+func testDecrypt(name, key, ciphertext string) {
+	fmt.Printf("%v, key %q\n", name, key)
+	c, err := newCipher(name, key)
+	if err != nil {
+		fmt.Println("Error:", err)
+		fmt.Println("========")
+		return
+	}
+	fmt.Printf("Ciphertext: %v\n", ciphertext)
+	decrypted, err := c.decrypt(ciphertext)
+	if err != nil {
+		fmt.Println("Error:", err)
+		fmt.Println("========")
+		return
+	}
+	fmt.Printf("Decrypted:  %v\n", decrypted)
+	fmt.Println("========")
+}
+
+func main() {
+	name := flag.String("cipher", "", "cipher to use, see -list")
+	key := flag.String("key", "", "the key")
+	list := flag.Bool("list", false, "list the ciphers and their keys")
+	flag.Parse()
+
+	if *list {
+		listCiphers()
+		return
+	}
+	if *name != "" {
+		if flag.NArg() != 2 {
+			fmt.Fprintln(os.Stderr, "usage: go run . -cipher NAME -key KEY encrypt|decrypt TEXT")
+			os.Exit(2)
+		}
+		c, err := newCipher(*name, *key)
+		if err != nil {
+			log.Fatal(err)
+		}
+		switch flag.Arg(0) {
+		case "encrypt":
+			fmt.Println(c.encrypt(flag.Arg(1)))
+		case "decrypt":
+			plaintext, err := c.decrypt(flag.Arg(1))
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(plaintext)
+		default:
+			log.Fatalf("unknown command %q, use encrypt or decrypt", flag.Arg(0))
+		}
+		return
+	}
+
+	// examples from Wikipedia
+	test("caesar", "3", "Veni, vidi, vici.")
+	test("affine", "5,8", "Affine cipher")
+	test("affine", "13,8", "Affine cipher")
+	test("atbash", "", "Hello, World!")
+	test("playfair", "playfair example", "Hide the gold in the tree stump")
+	testDecrypt("playfair", "playfair example", "BMODZBXDNABEKUDMUIXMMOUVI")
+	test("hill", "GYBNQKURP", "act")
+	test("hill", "GYB NQK URP", "act")
+	test("hill", "3 3 2 5", "help")
+	test("hill", "2 4 6 8", "help")
+	test("railfence", "3", "WEAREDISCOVEREDFLEEATONCE")
+	test("columnar", "ZEBRAS", "WEAREDISCOVEREDFLEEATONCE")
+	test("vigenere", "lemon", "Attack at dawn!")
+	test("vigenere", "lem0n", "Attack at dawn!")
+	test("enigma", "", "Attack at dawn!")
+}
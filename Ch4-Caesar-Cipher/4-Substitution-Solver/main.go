@@ -0,0 +1,273 @@
+/*
+Substitution Solver
+A Caesar cipher has 26 keys. A general substitution cipher can map every letter to any other letter,
+as long as no two letters map to the same one:
+
+plain   abcdefghijklmnopqrstuvwxyz
+cipher  qwertyuiopasdfghjklzxcvbnm
+
+That's 26! = 4 * 10^26 keys, about 88 bits. Far too many to try them all, and yet these ciphers are
+solved by hand in newspaper puzzles every day. The key space is huge, but it's smooth: a key that
+gets 20 letters right produces text that looks a lot more like English than a key that gets 5 right.
+So instead of searching, we climb:
+
+1. Start with a random key.
+2. Swap two letters of the key. If the decryption looks more like English, keep the swap.
+3. Repeat until no swap has helped for a long time.
+4. Start over from a new random key a few times, and keep the best result. A single climb can get
+   stuck on a "hill" that isn't the highest one.
+
+"Looks like English" is measured with quadgrams, groups of 4 letters. We count every quadgram in
+corpus.txt, about 110KB of public-domain English (passages from Project Gutenberg books, the King
+James Bible and US founding documents). It shares no text with the demo messages, otherwise the
+solver would only be recognising what it had already seen. A decryption's score is the sum of
+log10(probability) of each of its quadgrams. "tion" and "ther" are common, "qzxj" never happens.
+Quadgrams never seen in the corpus get a small floor probability instead of 0, so a single odd
+quadgram doesn't make the score -infinity.
+
+Letters that don't appear in the ciphertext can't be recovered, they're shown as "?" in the key.
+Rare letters like x, j and q only show up once or twice, so the statistics barely notice if they're
+swapped with each other. A reader fixes those at a glance. And below 100 letters or so there isn't
+enough text for the statistics to work at all, the last demo message is too short.
+
+Usage
+go run . CIPHERTEXT
+Running with no arguments runs the demo.
+*/
+
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+)
+
+//go:embed corpus.txt
+var corpus string
+
+const (
+	restarts        = 50
+	maxFailedSwaps  = 5000
+	quadgramSpace   = 26 * 26 * 26 * 26
+	floorMultiplier = 0.01
+)
+
+// quadgrams holds log10 probabilities of every quadgram, indexed by a*26^3 + b*26^2 + c*26 + d
+type quadgrams struct {
+	logProb []float64
+}
+
+func newQuadgrams(text string) quadgrams {
+	ls := letters(text)
+	counts := make([]int, quadgramSpace)
+	total := 0
+	for i := 0; i+4 <= len(ls); i++ {
+		counts[quadgramIndex(ls[i:i+4])]++
+		total++
+	}
+	q := quadgrams{logProb: make([]float64, quadgramSpace)}
+	floor := math.Log10(floorMultiplier / float64(total))
+	for i, c := range counts {
+		if c == 0 {
+			q.logProb[i] = floor
+			continue
+		}
+		q.logProb[i] = math.Log10(float64(c) / float64(total))
+	}
+	return q
+}
+
+func quadgramIndex(l []int) int {
+	return ((l[0]*26+l[1])*26+l[2])*26 + l[3]
+}
+
+// score is the log10 probability of ls as English. Higher is better.
+func (q quadgrams) score(ls []int) float64 {
+	s := 0.0
+	for i := 0; i+4 <= len(ls); i++ {
+		s += q.logProb[quadgramIndex(ls[i:i+4])]
+	}
+	return s
+}
+
+// letters returns only the letters of text, lowercased, as 0-25
+func letters(text string) []int {
+	out := []int{}
+	for _, c := range strings.ToLower(text) {
+		if c >= 'a' && c <= 'z' {
+			out = append(out, int(c-'a'))
+		}
+	}
+	return out
+}
+
+// key maps every plaintext letter to a ciphertext letter, key[0] is what 'a' encrypts to
+type key [26]int
+
+func (k key) inverse() key {
+	inv := key{}
+	for plain, cipher := range k {
+		inv[cipher] = plain
+	}
+	return inv
+}
+
+func (k key) String() string {
+	b := make([]byte, 26)
+	for i, c := range k {
+		b[i] = byte('a' + c)
+	}
+	return string(b)
+}
+
+func randomKey(r *rand.Rand) key {
+	k := key{}
+	for i, v := range r.Perm(26) {
+		k[i] = v
+	}
+	return k
+}
+
+// substitute replaces every letter using k, keeping case and everything that isn't a letter
+func substitute(text string, k key) string {
+	sb := strings.Builder{}
+	for _, c := range text {
+		switch {
+		case c >= 'a' && c <= 'z':
+			sb.WriteByte(byte('a' + k[c-'a']))
+		case c >= 'A' && c <= 'Z':
+			sb.WriteByte(byte('A' + k[c-'A']))
+		default:
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+func encrypt(plaintext string, k key) string {
+	return substitute(plaintext, k)
+}
+
+func decrypt(ciphertext string, k key) string {
+	return substitute(ciphertext, k.inverse())
+}
+
+type solution struct {
+	key   key
+	score float64
+	// present[c] reports whether cipher letter c appears in the ciphertext
+	present [26]bool
+}
+
+// mapping shows the key as plaintext -> ciphertext, with "?" for plaintext
+// letters whose ciphertext letter never appeared
+func (s solution) mapping() string {
+	b := make([]byte, 26)
+	for plain, cipher := range s.key {
+		b[plain] = '?'
+		if s.present[cipher] {
+			b[plain] = byte('a' + cipher)
+		}
+	}
+	return string(b)
+}
+
+// climb improves a decryption key by swapping pairs of letters until
+// maxFailedSwaps swaps in a row haven't improved the score
+func climb(r *rand.Rand, q quadgrams, ls []int, dec key) (key, float64) {
+	plain := make([]int, len(ls))
+	apply := func() {
+		for i, l := range ls {
+			plain[i] = dec[l]
+		}
+	}
+	apply()
+	best := q.score(plain)
+	for failed := 0; failed < maxFailedSwaps; {
+		a, b := r.Intn(26), r.Intn(26)
+		if a == b {
+			continue
+		}
+		dec[a], dec[b] = dec[b], dec[a]
+		apply()
+		if s := q.score(plain); s > best {
+			best = s
+			failed = 0
+			continue
+		}
+		dec[a], dec[b] = dec[b], dec[a]
+		failed++
+	}
+	return dec, best
+}
+
+// solve finds the most English-looking decryption of ciphertext
+func solve(ciphertext string, q quadgrams, r *rand.Rand) solution {
+	ls := letters(ciphertext)
+	s := solution{score: math.Inf(-1)}
+	for _, l := range ls {
+		s.present[l] = true
+	}
+	for i := 0; i < restarts; i++ {
+		dec, score := climb(r, q, ls, randomKey(r))
+		if score > s.score {
+			// dec maps cipher -> plain, the key maps plain -> cipher
+			s.key, s.score = dec.inverse(), score
+		}
+	}
+	return s
+}
+
+func test(q quadgrams, plaintext string, seed int64) {
+	r := rand.New(rand.NewSource(seed))
+	k := randomKey(r)
+	ciphertext := encrypt(plaintext, k)
+	fmt.Printf("Ciphertext (%v letters): %v\n", len(letters(ciphertext)), ciphertext)
+
+	s := solve(ciphertext, q, r)
+	fmt.Printf("Decrypted: %v\n", decrypt(ciphertext, s.key))
+	fmt.Printf("plain:     %v\n", "abcdefghijklmnopqrstuvwxyz")
+	fmt.Printf("found key: %v\n", s.mapping())
+	fmt.Printf("real key:  %v\n", k)
+
+	right, known := 0, 0
+	for plain := range k {
+		if !s.present[k[plain]] {
+			continue
+		}
+		known++
+		if s.key[plain] == k[plain] {
+			right++
+		}
+	}
+	fmt.Printf("%v of %v recoverable letters right, score %.1f\n", right, known, s.score)
+	fmt.Println("========")
+}
+
+func main() {
+	q := newQuadgrams(corpus)
+
+	if len(os.Args) > 1 {
+		ciphertext := strings.Join(os.Args[1:], " ")
+		s := solve(ciphertext, q, rand.New(rand.NewSource(1)))
+		fmt.Printf("plain:  %v\n", "abcdefghijklmnopqrstuvwxyz")
+		fmt.Printf("cipher: %v\n", s.mapping())
+		fmt.Println(decrypt(ciphertext, s.key))
+		return
+	}
+
+	test(q, "The Caesar cipher has only twenty six keys, so an attacker can simply try all of them. "+
+		"A general substitution cipher replaces every letter with any other letter, which gives more keys "+
+		"than there are grains of sand on every beach in the world. It still falls to a patient attacker, "+
+		"because the cipher does nothing to hide how often each letter and each group of letters appears.", 1)
+
+	test(q, "We have been asked to check whether the old reports from the vendor were protected properly. "+
+		"They were not. Every report used the same letter table, and after reading a few pages we could "+
+		"guess most of the words without any help from a computer.", 2)
+
+	test(q, "Meet me at the north gate at midnight and bring the papers with you.", 3)
+}
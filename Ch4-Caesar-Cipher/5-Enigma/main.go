@@ -0,0 +1,445 @@
+/*
+Enigma
+getOffsetChar shifts a letter by a fixed offset. A rotor machine is what you get when the offset
+changes after every letter, and the substitution is a scrambled alphabet instead of a shift.
+
+The Enigma I and M3, used by the German army and navy in WW2, have:
+
+Plugboard  - up to 13 cables, each swapping a pair of letters on the way in and on the way out.
+Rotors     - 3 rotors picked from a box of 5 (I-V) or 8 (I-VIII), in any order. Each is a wired,
+             scrambled alphabet. The signal goes through right, middle, left...
+Reflector  - ...is wired back through a different path (B or C)...
+Rotors     - ...and goes back through the rotors left, middle, right.
+
+Pressing a key first turns the rotors, then encrypts. The right rotor turns on every key, like the
+last digit of an odometer. When a rotor passes its notch it carries the rotor to its left along.
+Because the middle rotor's own notch pawl also pushes it, the middle rotor turns twice in a row when
+it reaches its notch: the "double stepping" anomaly. With rotors I II III starting at ADU:
+
+ADU -> ADV -> AEW -> BFX -> BFY       (the middle rotor steps at V->W and again at E->F)
+
+The ring setting (Ringstellung) turns the wiring relative to the letters and the notch, and the
+start position (Grundstellung) is what the operator sets before typing.
+
+The reflector makes Enigma its own inverse: the same settings encrypt and decrypt. It also means a
+letter can never encrypt to itself. That flaw let codebreakers slide a guessed piece of plaintext,
+a "crib" like WETTERVORHERSAGE (weather forecast), along the ciphertext and throw away every
+position where a letter lines up with itself. The Turing bombe then ran through rotor orders and
+start positions looking for one consistent with the crib. Our bombe does a reduced version of that:
+it knows the reflector, assumes the rings are at A and there's no plugboard, and tries every order of
+3 rotors out of I-V and every start position, 60 * 26^3 = about a million settings.
+
+Usage
+go run . -rotors "II IV V" -reflector B -rings BUL -pos BLA -plugs "AV BS CG" TEXT
+Running with no arguments runs the demo.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+type rotorSpec struct {
+	wiring  string
+	notches string
+}
+
+var rotorSpecs = map[string]rotorSpec{
+	"I":    {"EKMFLGDQVZNTOWYHXUSPAIBRCJ", "Q"},
+	"II":   {"AJDKSIRUXBLHWTMCQGZNPYFVOE", "E"},
+	"III":  {"BDFHJLCPRTXVZNYEIWGAKMUSQO", "V"},
+	"IV":   {"ESOVPZJAYQUIRHXLNFTGKDCMWB", "J"},
+	"V":    {"VZBRGITYUPSDNHLXAWMJQOFECK", "Z"},
+	"VI":   {"JPGVOUMFYQBENHZRDKASXLICTW", "ZM"},
+	"VII":  {"NZJHGRCXMYSWBOUFAIVLPEKQDT", "ZM"},
+	"VIII": {"FKQHTLXOCBJSPDZRAMEWNIUYGV", "ZM"},
+}
+
+var reflectors = map[string]string{
+	"B": "YRUHQSLDPXNGOKMIEBFZCWVJAT",
+	"C": "FVPJIAOYEDRZXWGCTKUQSBNMHL",
+}
+
+type config struct {
+	rotors    [3]string // left, middle, right
+	reflector string
+	rings     string // 3 letters, A is ring setting 01
+	positions string // 3 letters
+	plugboard string // pairs separated by spaces, e.g. "AV BS"
+}
+
+func (c config) String() string {
+	plugs := c.plugboard
+	if plugs == "" {
+		plugs = "none"
+	}
+	return fmt.Sprintf("rotors %v, reflector %v, rings %v, start %v, plugs %v",
+		strings.Join(c.rotors[:], " "), c.reflector, c.rings, c.positions, plugs)
+}
+
+type rotor struct {
+	forward  [26]int
+	backward [26]int
+	notch    [26]bool
+	ring     int
+	pos      int
+}
+
+func (r *rotor) atNotch() bool {
+	return r.notch[r.pos]
+}
+
+func (r *rotor) step() {
+	r.pos = (r.pos + 1) % 26
+}
+
+// through passes a letter through the rotor, the wiring shifted by position minus ring setting
+func (r *rotor) through(c int, wiring *[26]int) int {
+	shift := (r.pos - r.ring + 26) % 26
+	return (wiring[(c+shift)%26] - shift + 26) % 26
+}
+
+type enigma struct {
+	rotors    [3]rotor // left, middle, right
+	reflector [26]int
+	plugboard [26]int
+}
+
+// letterIndexes parses exactly n letters into 0-25
+func letterIndexes(s string, n int) ([]int, error) {
+	s = strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+	if len(s) != n {
+		return nil, fmt.Errorf("%q should be %d letters", s, n)
+	}
+	out := make([]int, n)
+	for i := range s {
+		v := strings.IndexByte(alphabet, s[i])
+		if v < 0 {
+			return nil, fmt.Errorf("%q is not a letter", s[i])
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func newEnigma(c config) (*enigma, error) {
+	e := &enigma{}
+
+	rings, err := letterIndexes(c.rings, 3)
+	if err != nil {
+		return nil, fmt.Errorf("rings: %w", err)
+	}
+	positions, err := letterIndexes(c.positions, 3)
+	if err != nil {
+		return nil, fmt.Errorf("positions: %w", err)
+	}
+	used := map[string]bool{}
+	for i, name := range c.rotors {
+		spec, ok := rotorSpecs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown rotor %q", name)
+		}
+		if used[name] {
+			return nil, fmt.Errorf("rotor %v used twice, there's only one of each", name)
+		}
+		used[name] = true
+		r := &e.rotors[i]
+		for j := range spec.wiring {
+			out := int(spec.wiring[j] - 'A')
+			r.forward[j] = out
+			r.backward[out] = j
+		}
+		for _, n := range spec.notches {
+			r.notch[n-'A'] = true
+		}
+		r.ring, r.pos = rings[i], positions[i]
+	}
+
+	reflector, ok := reflectors[strings.ToUpper(c.reflector)]
+	if !ok {
+		return nil, fmt.Errorf("unknown reflector %q", c.reflector)
+	}
+	for i := range reflector {
+		e.reflector[i] = int(reflector[i] - 'A')
+	}
+
+	for i := range e.plugboard {
+		e.plugboard[i] = i
+	}
+	for _, pair := range strings.Fields(strings.ToUpper(c.plugboard)) {
+		p, err := letterIndexes(pair, 2)
+		if err != nil {
+			return nil, fmt.Errorf("plugboard: %w", err)
+		}
+		a, b := p[0], p[1]
+		if a == b || e.plugboard[a] != a || e.plugboard[b] != b {
+			return nil, fmt.Errorf("plugboard: %v reuses a letter", pair)
+		}
+		e.plugboard[a], e.plugboard[b] = b, a
+	}
+	return e, nil
+}
+
+// step turns the rotors before every key press. The middle rotor steps when
+// the right rotor is at its notch, and also when it's at its own notch, which
+// takes the left rotor along: the double step.
+func (e *enigma) step() {
+	left, middle, right := &e.rotors[0], &e.rotors[1], &e.rotors[2]
+	if middle.atNotch() {
+		middle.step()
+		left.step()
+	} else if right.atNotch() {
+		middle.step()
+	}
+	right.step()
+}
+
+func (e *enigma) positions() string {
+	b := make([]byte, 3)
+	for i, r := range e.rotors {
+		b[i] = alphabet[r.pos]
+	}
+	return string(b)
+}
+
+// press encrypts one letter, 0-25
+func (e *enigma) press(c int) int {
+	e.step()
+	c = e.plugboard[c]
+	for i := 2; i >= 0; i-- {
+		c = e.rotors[i].through(c, &e.rotors[i].forward)
+	}
+	c = e.reflector[c]
+	for i := 0; i < 3; i++ {
+		c = e.rotors[i].through(c, &e.rotors[i].backward)
+	}
+	return e.plugboard[c]
+}
+
+// crypt encrypts or decrypts text, they're the same operation. Anything that isn't a letter is
+// dropped, Enigma didn't have a space bar.
+func (e *enigma) crypt(text string) string {
+	sb := strings.Builder{}
+	for _, c := range strings.ToUpper(text) {
+		if c < 'A' || c > 'Z' {
+			continue
+		}
+		sb.WriteByte(alphabet[e.press(int(c-'A'))])
+	}
+	return sb.String()
+}
+
+func crypt(c config, text string) (string, error) {
+	e, err := newEnigma(c)
+	if err != nil {
+		return "", err
+	}
+	return e.crypt(text), nil
+}
+
+// groups splits text into the 5 letter groups operators sent
+func groups(text string) string {
+	parts := []string{}
+	for i := 0; i < len(text); i += 5 {
+		end := i + 5
+		if end > len(text) {
+			end = len(text)
+		}
+		parts = append(parts, text[i:end])
+	}
+	return strings.Join(parts, " ")
+}
+
+// cribPositions returns every offset where crib could sit in ciphertext.
+// Enigma never encrypts a letter to itself, so any offset where the two share
+// a letter in the same place is impossible.
+func cribPositions(ciphertext, crib string) []int {
+	out := []int{}
+	for offset := 0; offset+len(crib) <= len(ciphertext); offset++ {
+		possible := true
+		for i := range crib {
+			if crib[i] == ciphertext[offset+i] {
+				possible = false
+				break
+			}
+		}
+		if possible {
+			out = append(out, offset)
+		}
+	}
+	return out
+}
+
+// bombe tries every order of 3 rotors out of candidates and every start position,
+// and returns the settings that turn the crib at offset into the ciphertext.
+// It assumes rings AAA and no plugboard.
+func bombe(ciphertext, crib string, offset int, candidates []string, reflector string) ([]config, error) {
+	if offset < 0 || offset+len(crib) > len(ciphertext) {
+		return nil, errors.New("crib doesn't fit in the ciphertext at that offset")
+	}
+	found := []config{}
+	for _, a := range candidates {
+		for _, b := range candidates {
+			for _, c := range candidates {
+				if a == b || b == c || a == c {
+					continue
+				}
+				cfg := config{rotors: [3]string{a, b, c}, reflector: reflector, rings: "AAA", positions: "AAA"}
+				e, err := newEnigma(cfg)
+				if err != nil {
+					return nil, err
+				}
+				for start := 0; start < 26*26*26; start++ {
+					e.rotors[0].pos, e.rotors[1].pos, e.rotors[2].pos = start/676, start/26%26, start%26
+					cfg.positions = e.positions()
+					if matchesCrib(e, ciphertext, crib, offset) {
+						found = append(found, cfg)
+					}
+				}
+			}
+		}
+	}
+	return found, nil
+}
+
+func matchesCrib(e *enigma, ciphertext, crib string, offset int) bool {
+	for i := 0; i < offset; i++ {
+		e.step()
+	}
+	for i := range crib {
+		if e.press(int(crib[i]-'A')) != int(ciphertext[offset+i]-'A') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseRings accepts letters ("BUL") or ring numbers ("2 21 12")
+func parseRings(s string) (string, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 {
+		return s, nil
+	}
+	b := make([]byte, 3)
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return s, nil
+		}
+		if n < 1 || n > 26 {
+			return "", fmt.Errorf("ring setting %d is out of range 1-26", n)
+		}
+		b[i] = alphabet[n-1]
+	}
+	return string(b), nil
+}
+
+func test(name string, c config, input, expected string) {
+	fmt.Println(name)
+	fmt.Println(" ", c)
+	output, err := crypt(c, input)
+	if err != nil {
+		fmt.Println("  Error:", err)
+		fmt.Println("========")
+		return
+	}
+	fmt.Printf("  input:  %v\n", groups(strings.ToUpper(strings.ReplaceAll(input, " ", ""))))
+	fmt.Printf("  output: %v\n", groups(output))
+	if expected != "" {
+		match := "matches"
+		if output != strings.ReplaceAll(expected, " ", "") {
+			match = "DOES NOT match"
+		}
+		fmt.Printf("  %v the published text\n", match)
+	}
+	fmt.Println("========")
+}
+
+func main() {
+	rotors := flag.String("rotors", "", "3 rotors, left to right, e.g. \"I II III\"")
+	reflector := flag.String("reflector", "B", "reflector, B or C")
+	rings := flag.String("rings", "AAA", "ring settings as letters (AAA) or numbers (\"1 1 1\")")
+	pos := flag.String("pos", "AAA", "start positions")
+	plugs := flag.String("plugs", "", "plugboard pairs, e.g. \"AV BS CG\"")
+	flag.Parse()
+
+	if *rotors != "" {
+		names := strings.Fields(strings.ToUpper(*rotors))
+		if len(names) != 3 {
+			log.Fatal("need exactly 3 rotors")
+		}
+		r, err := parseRings(*rings)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c := config{rotors: [3]string{names[0], names[1], names[2]}, reflector: *reflector, rings: r, positions: *pos, plugboard: *plugs}
+		out, err := crypt(c, strings.Join(flag.Args(), ""))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(groups(out))
+		return
+	}
+
+	e, err := newEnigma(config{rotors: [3]string{"I", "II", "III"}, reflector: "B", rings: "AAA", positions: "ADU"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	steps := []string{e.positions()}
+	for i := 0; i < 4; i++ {
+		e.step()
+		steps = append(steps, e.positions())
+	}
+	fmt.Printf("Double stepping: %v\n", strings.Join(steps, " -> "))
+	fmt.Println("========")
+
+	test("Enigma I, everything at A",
+		config{rotors: [3]string{"I", "II", "III"}, reflector: "B", rings: "AAA", positions: "AAA"},
+		"AAAAA", "BDZGO")
+
+	// Operation Barbarossa, 7 July 1941, the first part of a two-part message
+	barbarossa := config{
+		rotors: [3]string{"II", "IV", "V"}, reflector: "B", rings: "BUL", positions: "BLA",
+		plugboard: "AV BS CG DL FU HZ IN KM OW RX",
+	}
+	test("Operation Barbarossa, 1941", barbarossa,
+		"EDPUD NRGYS ZRCXN UYTPO MRMBO FKTBZ REZKM LXLVE FGUEY SIOZV EQMIK UBPMM YLKLT TDEIS MDICA GYKUA "+
+			"CTCDO MOHWX MUUIA UBSTS LRNBZ SZWNR FXWFY SSXJZ VIJHI DISHP RKLKA YUPAD TXQSP INQMA TLPIF SVKDA "+
+			"SCTAC DPBOP VHJK",
+		"AUFKL XABTE ILUNG XVONX KURTI NOWAX KURTI NOWAX NORDW ESTLX SEBEZ XSEBE ZXUAF FLIEG ERSTR ASZER "+
+			"IQTUN GXDUB ROWKI XDUBR OWKIX OPOTS CHKAX OPOTS CHKAX UMXEI NSAQT DREIN ULLXU HRANG ETRET ENXAN "+
+			"GRIFF XINFX RGTX")
+
+	test("Rotor used twice",
+		config{rotors: [3]string{"I", "I", "III"}, reflector: "B", rings: "AAA", positions: "AAA"}, "HELLO", "")
+	test("Plug reused",
+		config{rotors: [3]string{"I", "II", "III"}, reflector: "B", rings: "AAA", positions: "AAA", plugboard: "AB BC"}, "HELLO", "")
+
+	secret := config{rotors: [3]string{"IV", "I", "V"}, reflector: "B", rings: "AAA", positions: "PSL"}
+	ciphertext, err := crypt(secret, "WETTERVORHERSAGEBISKAYA HEUTE REGEN UND STARKER WIND AUS WEST")
+	if err != nil {
+		log.Fatal(err)
+	}
+	crib := "WETTERVORHERSAGE"
+	fmt.Printf("Bombe: ciphertext %v\n", groups(ciphertext))
+	fmt.Printf("Crib %v could be at offsets %v\n", crib, cribPositions(ciphertext, crib))
+	found, err := bombe(ciphertext, crib, 0, []string{"I", "II", "III", "IV", "V"}, "B")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, c := range found {
+		plaintext, err := crypt(c, ciphertext)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("  %v: %v\n", c, plaintext)
+	}
+}
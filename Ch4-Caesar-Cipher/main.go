@@ -95,7 +95,11 @@ func main() {
 
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"sort"
+)
 
 func encrypt(plaintext string, key int) string {
 	return crypt(plaintext, key)
@@ -118,18 +122,137 @@ func crypt(text string, key int) string {
 // since the alphabet is finite,
 // we need to use the modulo operator % to "wrap around" the index
 // if it exceeds the length of the alphabet.
+// Uppercase letters are shifted within the uppercase alphabet, and
+// anything that isn't a letter is passed through unchanged.
 func getOffsetChar(c rune, offset int) string {
 	const alphabet = "abcdefghijklmnopqrstuvwxyz"
-	for i, curr := range alphabet {
-		if curr == c {
-			modI := (i + offset) % len(alphabet)
-			if modI < 0 {
-				modI += len(alphabet)
+	const upperAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	for _, a := range []string{alphabet, upperAlphabet} {
+		for i, curr := range a {
+			if curr == c {
+				modI := (i + offset) % len(a)
+				if modI < 0 {
+					modI += len(a)
+				}
+				return string(a[modI])
 			}
-			return string(alphabet[modI])
 		}
 	}
-	return ""
+	return string(c)
+}
+
+// englishFrequencies is how often each letter a-z appears in English text
+var englishFrequencies = [26]float64{
+	0.08167, 0.01492, 0.02782, 0.04253, 0.12702, 0.02228, 0.02015, 0.06094, 0.06966,
+	0.00153, 0.00772, 0.04025, 0.02406, 0.06749, 0.07507, 0.01929, 0.00095, 0.05987,
+	0.06327, 0.09056, 0.02758, 0.00978, 0.02360, 0.00150, 0.01974, 0.00074,
+}
+
+// letterCounts counts each letter a-z, ignoring case and everything that isn't a letter
+func letterCounts(text string) ([26]int, int) {
+	counts := [26]int{}
+	total := 0
+	for _, c := range text {
+		switch {
+		case c >= 'a' && c <= 'z':
+			counts[c-'a']++
+		case c >= 'A' && c <= 'Z':
+			counts[c-'A']++
+		default:
+			continue
+		}
+		total++
+	}
+	return counts, total
+}
+
+// chiSquared measures how far the letter counts of text are from English.
+// Lower is more English-like.
+func chiSquared(text string) float64 {
+	counts, total := letterCounts(text)
+	if total == 0 {
+		// no letters, no evidence either way
+		return 0
+	}
+	score := 0.0
+	for i, f := range englishFrequencies {
+		expected := f * float64(total)
+		diff := float64(counts[i]) - expected
+		score += diff * diff / expected
+	}
+	return score
+}
+
+type candidate struct {
+	key        int
+	plaintext  string
+	chiSquared float64
+	confidence float64 // between 0 and 1, summed over all keys it's 1
+}
+
+// breakCaesar tries all 26 keys and returns them ranked from most to least likely.
+// Confidence treats each chi-squared score as a log-likelihood, exp(-chi²/2),
+// normalized over all 26 keys. Text without letters gives every key the same
+// confidence, and they stay in key order.
+func breakCaesar(ciphertext string) []candidate {
+	candidates := make([]candidate, 26)
+	for key := range candidates {
+		plaintext := decrypt(ciphertext, key)
+		candidates[key] = candidate{key: key, plaintext: plaintext, chiSquared: chiSquared(plaintext)}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].chiSquared < candidates[j].chiSquared
+	})
+
+	// subtract the best score first so exp doesn't underflow to 0 on long texts
+	best := candidates[0].chiSquared
+	sum := 0.0
+	for i := range candidates {
+		candidates[i].confidence = math.Exp(-(candidates[i].chiSquared - best) / 2)
+		sum += candidates[i].confidence
+	}
+	for i := range candidates {
+		candidates[i].confidence /= sum
+	}
+	return candidates
+}
+
+/*
+Breaking it automatically
+Looking for the most common letter only works on long texts. breakCaesar instead tries all 26 keys
+and scores every decryption with a chi-squared test against English letter frequencies:
+
+chi² = sum over a-z of (observed - expected)² / expected
+
+where expected = frequency of the letter in English * number of letters in the text. The correct
+key gives the most English-looking text and the lowest score. Short texts like "Veni, vidi, vici."
+don't have enough letters for the statistics to work, so the right key can lose to a wrong one.
+That's why we return every key ranked, not just the best.
+*/
+
+
+func testBreak(plaintext string, key int) {
+	ciphertext := encrypt(plaintext, key)
+	fmt.Printf("Breaking %v\n", ciphertext)
+	candidates := breakCaesar(ciphertext)
+	for _, c := range candidates[:3] {
+		fmt.Printf("  key %2v  chi² %8.1f  confidence %6.2f%%  %v\n", c.key, c.chiSquared, 100*c.confidence, c.plaintext)
+	}
+	if candidates[0].key != key {
+		fmt.Printf("  wrong, the key was %v\n", key)
+	}
+	fmt.Println("========")
+}
+
+// init runs the new tests before main runs the original ones
+func init() {
+	test("Hello, World! 123", 5)
+
+	testBreak("Veni, vidi, vici.", 3)
+	testBreak("The quick brown fox jumps over the lazy dog.", 13)
+	testBreak("To demonstrate the superiority of our modern ciphers at Passly, we've been asked to implement a Caesar cipher.", 7)
+	testBreak("It's so the marketing team can show off how much better our algorithms are than the ones used by the Romans. Go figure.", 22)
+	testBreak("1234 !!", 4)
 }
 
 // don't touch below this line
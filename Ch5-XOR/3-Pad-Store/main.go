@@ -0,0 +1,440 @@
+/*
+Pad Store
+The One Time Pad is only perfectly secure if every byte of the pad is used once. crypt happily
+XORs with whatever key it's handed, so nothing stops two messages from being encrypted with the
+same pad bytes. When that happens the pad cancels out:
+
+c1 XOR c2 = (p1 XOR pad) XOR (p2 XOR pad) = p1 XOR p2
+
+and both messages can usually be recovered (see the Two-Time Pad lesson).
+
+A pad store keeps track of which parts of the pad have been used:
+
+1. A pad is generated with crypto/rand, and the same file is given to both parties in person.
+2. Next to the pad is a small state file listing the byte ranges that are used up.
+3. To encrypt, take the next unused range, and save the state file BEFORE the ciphertext is
+   returned. If the program crashes after that, a few pad bytes are wasted, but they can never be
+   handed out twice.
+4. Each message starts with the pad's ID and the offset it was encrypted at, so the receiver
+   knows where to start in their copy. The receiver marks that range as used too, and refuses a
+   message whose range was already used: that's either a replay or a pad used twice.
+5. When the pad runs out, encryption stops. There's no way to stretch a one-time pad.
+
+message = hex(pad ID (8 bytes) || offset (8 bytes, big endian) || ciphertext)
+
+Two people sending at the same time from copies of the same pad can still pick the same range.
+The receiver will notice and refuse to decrypt, but the damage is done. In practice each direction
+gets its own pad.
+
+This only protects the pad. Like any XOR cipher, the ciphertext isn't authenticated: flipping a bit
+of the ciphertext flips the same bit of the plaintext.
+
+Usage
+go run . new -size 4096 alice.pad
+go run . encrypt alice.pad "meet me at midnight"
+go run . decrypt bob.pad MESSAGE
+go run . status alice.pad
+Running with no arguments runs the demo.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	padIDSize  = 8
+	headerSize = padIDSize + 8
+)
+
+var (
+	errPadExhausted = errors.New("pad exhausted")
+	errPadReused    = errors.New("pad range already used")
+	errWrongPad     = errors.New("message was encrypted with a different pad")
+	errTooShort     = errors.New("message is too short")
+)
+
+// span is a used range of the pad, [start, end)
+type span struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+type padState struct {
+	ID   string `json:"id"`
+	Size int64  `json:"size"`
+	Used []span `json:"used"`
+}
+
+// next returns the first offset after every used range
+func (s padState) next() int64 {
+	next := int64(0)
+	for _, u := range s.Used {
+		if u.End > next {
+			next = u.End
+		}
+	}
+	return next
+}
+
+func (s padState) overlaps(r span) bool {
+	for _, u := range s.Used {
+		if r.Start < u.End && u.Start < r.End {
+			return true
+		}
+	}
+	return false
+}
+
+func (s padState) remaining() int64 {
+	return s.Size - s.next()
+}
+
+type padStore struct {
+	path  string
+	state padState
+}
+
+func statePath(padPath string) string {
+	return padPath + ".state"
+}
+
+// writeFileSync writes data to a temp file, syncs it, and renames it over path,
+// so path always holds either the old or the new contents
+func writeFileSync(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	// sync the directory too, otherwise the rename itself can be lost in a crash
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+func (p *padStore) save() error {
+	sort.Slice(p.state.Used, func(i, j int) bool {
+		return p.state.Used[i].Start < p.state.Used[j].Start
+	})
+	data, err := json.MarshalIndent(p.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileSync(statePath(p.path), data)
+}
+
+// createPad writes size random bytes to path, and a fresh state file next to it
+func createPad(path string, size int64) (*padStore, error) {
+	if size <= 0 {
+		return nil, errors.New("pad size must be positive")
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%s already exists, refusing to overwrite a pad", path)
+	}
+	pad := make([]byte, size)
+	if _, err := rand.Read(pad); err != nil {
+		return nil, err
+	}
+	id := make([]byte, padIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	if err := writeFileSync(path, pad); err != nil {
+		return nil, err
+	}
+	p := &padStore{path: path, state: padState{ID: hex.EncodeToString(id), Size: size}}
+	return p, p.save()
+}
+
+// copyPad makes the copy that's handed to the other party, with nothing used yet
+func copyPad(src, dst string) (*padStore, error) {
+	from, err := openPad(src)
+	if err != nil {
+		return nil, err
+	}
+	pad, err := os.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileSync(dst, pad); err != nil {
+		return nil, err
+	}
+	p := &padStore{path: dst, state: padState{ID: from.state.ID, Size: from.state.Size}}
+	return p, p.save()
+}
+
+func openPad(path string) (*padStore, error) {
+	data, err := os.ReadFile(statePath(path))
+	if err != nil {
+		return nil, err
+	}
+	p := &padStore{path: path}
+	if err := json.Unmarshal(data, &p.state); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", statePath(path), err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() != p.state.Size {
+		return nil, fmt.Errorf("%s is %d bytes, the state file says %d", path, info.Size(), p.state.Size)
+	}
+	return p, nil
+}
+
+// readPad returns the pad bytes in r
+func (p *padStore) readPad(r span) ([]byte, error) {
+	f, err := os.Open(p.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	key := make([]byte, r.End-r.Start)
+	if _, err := f.ReadAt(key, r.Start); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return key, nil
+}
+
+func crypt(plaintext, key []byte) []byte {
+	ciphertext := make([]byte, len(plaintext))
+	for i := range plaintext {
+		keyByte := key[i%len(key)]
+		ciphertext[i] = plaintext[i] ^ keyByte
+	}
+	return ciphertext
+}
+
+func (p *padStore) encrypt(plaintext []byte) (string, error) {
+	if len(plaintext) == 0 {
+		return "", errors.New("nothing to encrypt")
+	}
+	r := span{Start: p.state.next()}
+	r.End = r.Start + int64(len(plaintext))
+	if r.End > p.state.Size {
+		return "", fmt.Errorf("%w: %d bytes left, need %d", errPadExhausted, p.state.remaining(), len(plaintext))
+	}
+	if p.state.overlaps(r) {
+		return "", errPadReused
+	}
+
+	// mark the range used on disk before the ciphertext ever leaves this function
+	p.state.Used = append(p.state.Used, r)
+	if err := p.save(); err != nil {
+		return "", err
+	}
+
+	key, err := p.readPad(r)
+	if err != nil {
+		return "", err
+	}
+	id, err := hex.DecodeString(p.state.ID)
+	if err != nil {
+		return "", err
+	}
+	out := append(id, binary.BigEndian.AppendUint64(nil, uint64(r.Start))...)
+	out = append(out, crypt(plaintext, key)...)
+	return hex.EncodeToString(out), nil
+}
+
+func (p *padStore) decrypt(message string) ([]byte, error) {
+	raw, err := hex.DecodeString(message)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) <= headerSize {
+		return nil, errTooShort
+	}
+	if hex.EncodeToString(raw[:padIDSize]) != p.state.ID {
+		return nil, errWrongPad
+	}
+	offset := binary.BigEndian.Uint64(raw[padIDSize:headerSize])
+	ciphertext := raw[headerSize:]
+	if offset > uint64(p.state.Size) || uint64(len(ciphertext)) > uint64(p.state.Size)-offset {
+		return nil, fmt.Errorf("%w: message needs bytes past the end of the pad", errPadExhausted)
+	}
+	r := span{Start: int64(offset), End: int64(offset) + int64(len(ciphertext))}
+	if p.state.overlaps(r) {
+		return nil, fmt.Errorf("%w: bytes %d-%d, replayed message or pad used twice", errPadReused, r.Start, r.End-1)
+	}
+
+	key, err := p.readPad(r)
+	if err != nil {
+		return nil, err
+	}
+	p.state.Used = append(p.state.Used, r)
+	if err := p.save(); err != nil {
+		return nil, err
+	}
+	return crypt(ciphertext, key), nil
+}
+
+func (p *padStore) status() string {
+	return fmt.Sprintf("pad %v: %v bytes, %v used ranges, %v bytes left",
+		p.state.ID, p.state.Size, len(p.state.Used), p.state.remaining())
+}
+
+func run(args []string) error {
+	cmd := flag.NewFlagSet(args[0], flag.ExitOnError)
+	size := cmd.Int64("size", 1<<20, "pad size in bytes")
+	if err := cmd.Parse(args[1:]); err != nil {
+		return err
+	}
+	rest := cmd.Args()
+
+	switch {
+	case args[0] == "new" && len(rest) == 1:
+		p, err := createPad(rest[0], *size)
+		if err != nil {
+			return err
+		}
+		fmt.Println(p.status())
+		return nil
+	case args[0] == "encrypt" && len(rest) == 2:
+		p, err := openPad(rest[0])
+		if err != nil {
+			return err
+		}
+		message, err := p.encrypt([]byte(rest[1]))
+		if err != nil {
+			return err
+		}
+		fmt.Println(message)
+		return nil
+	case args[0] == "decrypt" && len(rest) == 2:
+		p, err := openPad(rest[0])
+		if err != nil {
+			return err
+		}
+		plaintext, err := p.decrypt(rest[1])
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(plaintext))
+		return nil
+	case args[0] == "status" && len(rest) == 1:
+		p, err := openPad(rest[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(p.status())
+		return nil
+	}
+	return errors.New("usage: new [-size N] PAD | encrypt PAD TEXT | decrypt PAD MESSAGE | status PAD")
+}
+
+func send(p *padStore, plaintext string) string {
+	message, err := p.encrypt([]byte(plaintext))
+	if err != nil {
+		fmt.Printf("Encrypting %q: %v\n", plaintext, err)
+		return ""
+	}
+	fmt.Printf("Encrypted %q: %v\n", plaintext, message)
+	return message
+}
+
+func receive(p *padStore, message string) {
+	plaintext, err := p.decrypt(message)
+	if err != nil {
+		fmt.Printf("Decrypting: %v\n", err)
+		return
+	}
+	fmt.Printf("Decrypted: %q\n", plaintext)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() > 0 {
+		if err := run(flag.Args()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "pads")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	alice, err := createPad(filepath.Join(dir, "alice.pad"), 64)
+	if err != nil {
+		log.Fatal(err)
+	}
+	bob, err := copyPad(alice.path, filepath.Join(dir, "bob.pad"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(alice.status())
+	fmt.Println("========")
+
+	m1 := send(alice, "Shazam")
+	m2 := send(alice, "I'm lovin it")
+	receive(bob, m1)
+	receive(bob, m2)
+	fmt.Println("Replaying the first message:")
+	receive(bob, m1)
+	fmt.Println("========")
+
+	// Bob's copy knows Alice used the first 18 bytes, so his reply starts after them
+	reply := send(bob, "Don't tell him I'm in love")
+	receive(alice, reply)
+	fmt.Println("========")
+
+	// reopening reads the state back from disk, the used ranges survive a restart
+	alice, err = openPad(alice.path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("After reopening:", alice.status())
+	send(alice, "this message is too long for what's left of the pad")
+	send(alice, "short")
+	fmt.Println("========")
+
+	// Bob sends before receiving "short", so both pick the same range
+	clash := send(bob, "oops!")
+	receive(alice, clash)
+	fmt.Println("========")
+
+	tampered, _ := hex.DecodeString(m1)
+	tampered[0] ^= 0xff
+	other, err := createPad(filepath.Join(dir, "other.pad"), 64)
+	if err != nil {
+		log.Fatal(err)
+	}
+	receive(other, hex.EncodeToString(tampered))
+	receive(bob, hex.EncodeToString(bytes.Repeat([]byte{0}, headerSize)))
+	fmt.Println(alice.status())
+	fmt.Println(bob.status())
+}
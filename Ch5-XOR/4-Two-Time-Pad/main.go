@@ -0,0 +1,679 @@
+/*
+Two-Time Pad
+A one-time pad used twice isn't a one-time pad any more. XOR two ciphertexts made with the same
+key and the key cancels out:
+
+c1 XOR c2 = (p1 XOR key) XOR (p2 XOR key) = p1 XOR p2
+
+That's not either plaintext yet, but it's close. If we guess a word that's in one message, say
+" the ", and XOR it into p1 XOR p2 at the right offset, the other message appears at that spot.
+Slide the guess (the "crib") along every offset and look for places where the other message turns
+into readable text. That's crib dragging.
+
+Every correct guess also gives us the key at those positions (key = c1 XOR guess), which decrypts
+that part of every message encrypted with it. So each message we learn something about helps with
+all the others, and 3 messages are much easier than 2.
+
+Interactive mode lets you do this by hand:
+
+drag TEXT           slide TEXT over every message, show what it reveals in the others
+place M OFF TEXT    message M has TEXT at offset OFF, work out the key there
+show                show what we know of every message and the key
+auto                let the automatic solver continue from here
+quit
+
+Quote TEXT to keep spaces at the ends: drag " the "
+
+Automatic mode doesn't guess words, it guesses the key one byte at a time. Every key byte turns
+into one character in each message, and a language model says how likely each of those characters
+is after the ones before it. The model counts which character follows every run of up to 4
+characters in corpus.txt, the same 110KB of public-domain English as the Substitution Solver. It
+shares no text with the demo messages. A key that makes all messages read like English at once is
+almost certainly the right one:
+
+1. Start with an empty key and the messages at the start of a sentence.
+2. For every partial key, try each byte that makes the next character of message 0 printable, and
+   add up how likely the next character of every message is. Go on with the 20 best bytes.
+3. Keep the 200 best partial keys and move to the next position. Keeping more than the single
+   best lets a key that starts off worse catch up once a word is finished.
+4. At the end, take the best key. Key bytes already placed by hand are kept as they are. Where
+   only one message is left there's nothing to compare, so those key bytes stay unknown.
+
+Where both messages have the same few characters in the same place, a space for example, the model
+can't tell which message goes on which way, and the two can cross over: the rest of message 0 comes
+out as message 1 and the other way round. The key is wrong from there, key XOR p1 XOR p2, and only
+a third message shows it, because that one turns into garbage. With three messages nearly every
+character comes back. A person in interactive mode can also undo the crossing with place, and auto
+then carries on from there. -corpus trains the model on other text, more of it does better.
+
+With only two messages there's one thing no attack can tell: which plaintext goes with which
+ciphertext. p1 XOR p2 is the same as p2 XOR p1.
+
+Usage
+go run . HEX HEX [HEX...]
+go run . -i HEX HEX [HEX...]
+go run . -corpus book.txt HEX HEX
+Running with no arguments runs the demo.
+*/
+
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	modelOrder  = 5   // a character and up to 4 before it
+	beamWidth   = 200 // partial keys kept at every position
+	branching   = 20  // bytes tried for each of them
+	unknownChar = '_'
+)
+
+//go:embed corpus.txt
+var corpus string
+
+// log10 frequencies of characters in English text, spaces included
+var charFrequencies = map[byte]float64{}
+
+func init() {
+	letters := map[byte]float64{
+		'a': 0.0653, 'b': 0.0126, 'c': 0.0223, 'd': 0.0328, 'e': 0.1027, 'f': 0.0198, 'g': 0.0162,
+		'h': 0.0498, 'i': 0.0567, 'j': 0.0010, 'k': 0.0056, 'l': 0.0332, 'm': 0.0203, 'n': 0.0571,
+		'o': 0.0616, 'p': 0.0150, 'q': 0.0008, 'r': 0.0499, 's': 0.0532, 't': 0.0752, 'u': 0.0228,
+		'v': 0.0080, 'w': 0.0170, 'x': 0.0014, 'y': 0.0143, 'z': 0.0005,
+	}
+	for c, f := range letters {
+		charFrequencies[c] = math.Log10(f)
+		// capitals mostly start sentences, they're a lot rarer
+		charFrequencies[c-'a'+'A'] = math.Log10(f / 10)
+	}
+	charFrequencies[' '] = math.Log10(0.1829)
+	for _, c := range []byte(".,'") {
+		charFrequencies[c] = math.Log10(0.005)
+	}
+	for _, c := range []byte("0123456789!?-:;\"") {
+		charFrequencies[c] = math.Log10(0.0005)
+	}
+}
+
+// englishScore is the average log10 frequency of the characters in text.
+// Anything unlikely to be in a message, like control characters, scores -Inf.
+func englishScore(text []byte) float64 {
+	if len(text) == 0 {
+		return math.Inf(-1)
+	}
+	total := 0.0
+	for _, c := range text {
+		f, ok := charFrequencies[c]
+		if !ok {
+			if c < 0x20 || c > 0x7e {
+				return math.Inf(-1)
+			}
+			f = -5
+		}
+		total += f
+	}
+	return total / float64(len(text))
+}
+
+type session struct {
+	ciphertexts [][]byte
+	key         []byte
+	known       []bool
+	model       *languageModel
+}
+
+func newSession(ciphertexts [][]byte, model *languageModel) (*session, error) {
+	if len(ciphertexts) < 2 {
+		return nil, errors.New("need at least 2 ciphertexts encrypted with the same key")
+	}
+	longest := 0
+	for _, c := range ciphertexts {
+		if len(c) > longest {
+			longest = len(c)
+		}
+	}
+	return &session{
+		ciphertexts: ciphertexts,
+		key:         make([]byte, longest),
+		known:       make([]bool, longest),
+		model:       model,
+	}, nil
+}
+
+// keyFor returns the key bytes that put text in message msg at offset
+func (s *session) keyFor(msg, offset int, text []byte) ([]byte, error) {
+	if msg < 0 || msg >= len(s.ciphertexts) {
+		return nil, fmt.Errorf("there is no message %d", msg)
+	}
+	c := s.ciphertexts[msg]
+	if offset < 0 || offset+len(text) > len(c) {
+		return nil, fmt.Errorf("%q doesn't fit in message %d at offset %d", text, msg, offset)
+	}
+	key := make([]byte, len(text))
+	for i := range text {
+		key[i] = c[offset+i] ^ text[i]
+	}
+	return key, nil
+}
+
+// conflicts reports whether key at offset disagrees with a key byte we already know
+func (s *session) conflicts(offset int, key []byte) bool {
+	for i, k := range key {
+		if s.known[offset+i] && s.key[offset+i] != k {
+			return true
+		}
+	}
+	return false
+}
+
+// place says message msg has text at offset. It returns how many new key bytes that reveals.
+func (s *session) place(msg, offset int, text []byte) (int, error) {
+	key, err := s.keyFor(msg, offset, text)
+	if err != nil {
+		return 0, err
+	}
+	if s.conflicts(offset, key) {
+		return 0, fmt.Errorf("%q at offset %d contradicts what we already know", text, offset)
+	}
+	return s.setKey(offset, key), nil
+}
+
+func (s *session) setKey(offset int, key []byte) int {
+	added := 0
+	for i, k := range key {
+		if !s.known[offset+i] {
+			s.key[offset+i], s.known[offset+i] = k, true
+			added++
+		}
+	}
+	return added
+}
+
+// plaintext decrypts message msg as far as the key is known
+func (s *session) plaintext(msg int) string {
+	b := []byte{}
+	for i, c := range s.ciphertexts[msg] {
+		if !s.known[i] {
+			b = append(b, unknownChar)
+			continue
+		}
+		b = append(b, c^s.key[i])
+	}
+	return string(b)
+}
+
+type placement struct {
+	msg, offset int
+	text        []byte
+	key         []byte
+	revealed    [][]byte // what the placement shows in every other message, by message, nil where it shows nothing
+	score       float64
+}
+
+// drag slides crib over every message, and scores what it reveals in the
+// others. Placements that reveal something unprintable are left out.
+func (s *session) drag(crib []byte) []placement {
+	out := []placement{}
+	for msg, c := range s.ciphertexts {
+		for offset := 0; offset+len(crib) <= len(c); offset++ {
+			key, _ := s.keyFor(msg, offset, crib)
+			if s.conflicts(offset, key) {
+				continue
+			}
+			p := placement{msg: msg, offset: offset, text: crib, key: key, revealed: make([][]byte, len(s.ciphertexts))}
+			total, n := 0.0, 0
+			for other, oc := range s.ciphertexts {
+				if other == msg || offset+len(crib) > len(oc) {
+					continue
+				}
+				fragment := make([]byte, len(crib))
+				for i := range crib {
+					fragment[i] = oc[offset+i] ^ key[i]
+				}
+				p.revealed[other] = fragment
+				total += englishScore(fragment)
+				n++
+			}
+			if n == 0 || math.IsInf(total, -1) {
+				continue
+			}
+			p.score = total / float64(n)
+			out = append(out, p)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].score > out[j].score })
+	return out
+}
+
+// languageModel knows how often every character follows every run of up to order-1 characters
+type languageModel struct {
+	order  int
+	counts map[string]map[byte]int
+	totals map[string]int
+	cache  map[string]*[256]float64
+}
+
+func newLanguageModel(text string, order int) *languageModel {
+	m := &languageModel{
+		order:  order,
+		counts: map[string]map[byte]int{},
+		totals: map[string]int{},
+		cache:  map[string]*[256]float64{},
+	}
+	// line breaks and indents are layout, not text
+	t := strings.Join(strings.Fields(text), " ")
+	for i := 0; i < len(t); i++ {
+		for n := 0; n < order && n <= i; n++ {
+			context := t[i-n : i]
+			if m.counts[context] == nil {
+				m.counts[context] = map[byte]int{}
+			}
+			m.counts[context][t[i]]++
+			m.totals[context]++
+		}
+	}
+	return m
+}
+
+// logProbs returns the natural log probability of every byte following context.
+// It starts from every printable character being equally likely, then mixes in what
+// followed ever longer endings of context, as far as they were seen (Witten-Bell smoothing).
+// Anything that isn't printable ASCII is -Inf.
+func (m *languageModel) logProbs(context string) *[256]float64 {
+	if len(context) >= m.order {
+		context = context[len(context)-m.order+1:]
+	}
+	if p, ok := m.cache[context]; ok {
+		return p
+	}
+	probs := [256]float64{}
+	for c := 0x20; c <= 0x7e; c++ {
+		probs[c] = 1.0 / 95
+	}
+	for n := 0; n <= len(context); n++ {
+		ending := context[len(context)-n:]
+		total := float64(m.totals[ending])
+		if total == 0 {
+			break
+		}
+		seen := float64(len(m.counts[ending]))
+		for c := 0x20; c <= 0x7e; c++ {
+			probs[c] = (float64(m.counts[ending][byte(c)]) + seen*probs[c]) / (total + seen)
+		}
+	}
+	for c := range probs {
+		probs[c] = math.Log(probs[c])
+	}
+	m.cache[context] = &probs
+	return &probs
+}
+
+// partialKey is one entry of the beam: the key up to some position, and how the messages end there
+type partialKey struct {
+	score   float64
+	parent  int      // index of the partial key at the previous position
+	key     byte     // the key byte at this position
+	known   bool     // false where the key byte is left unknown
+	endings []string // the last few characters of every message
+}
+
+// decode fills in every unknown key byte with a beam search over the language model
+func (s *session) decode() {
+	start := partialKey{parent: -1, endings: make([]string, len(s.ciphertexts))}
+	for i := range start.endings {
+		// messages start the way sentences do
+		start.endings[i] = ". "
+	}
+	beam := []partialKey{start}
+	steps := [][]partialKey{}
+	for pos := range s.key {
+		active := []int{}
+		for msg, c := range s.ciphertexts {
+			if pos < len(c) {
+				active = append(active, msg)
+			}
+		}
+		candidates := []byte{}
+		if s.known[pos] {
+			candidates = append(candidates, s.key[pos])
+		} else if len(active) >= 2 {
+			for ch := byte(0x20); ch <= 0x7e; ch++ {
+				candidates = append(candidates, s.ciphertexts[active[0]][pos]^ch)
+			}
+		}
+
+		next := []partialKey{}
+		// partial keys that leave every message ending the same way can only differ in score so far
+		bySignature := map[string]int{}
+		for i, p := range beam {
+			if len(candidates) == 0 {
+				next = append(next, partialKey{score: p.score, parent: i, endings: p.endings})
+				continue
+			}
+			probs := make([]*[256]float64, len(active))
+			for j, msg := range active {
+				probs[j] = s.model.logProbs(p.endings[msg])
+			}
+			scores := make([]float64, len(candidates))
+			for c, k := range candidates {
+				scores[c] = p.score
+				for j, msg := range active {
+					scores[c] += probs[j][s.ciphertexts[msg][pos]^k]
+				}
+			}
+			// only the most likely bytes go on, the rest would fall out of the beam anyway
+			best := make([]int, len(candidates))
+			for c := range best {
+				best[c] = c
+			}
+			sort.SliceStable(best, func(a, b int) bool { return scores[best[a]] > scores[best[b]] })
+			if len(best) > branching {
+				best = best[:branching]
+			}
+			for _, c := range best {
+				k, score := candidates[c], scores[c]
+				if math.IsInf(score, -1) {
+					continue
+				}
+				endings := make([]string, len(p.endings))
+				copy(endings, p.endings)
+				for _, msg := range active {
+					ending := endings[msg] + string(s.ciphertexts[msg][pos]^k)
+					if len(ending) >= s.model.order {
+						ending = ending[len(ending)-s.model.order+1:]
+					}
+					endings[msg] = ending
+				}
+				candidate := partialKey{score: score, parent: i, key: k, known: true, endings: endings}
+				signature := strings.Join(endings, "\x00")
+				if j, ok := bySignature[signature]; ok {
+					if score > next[j].score {
+						next[j] = candidate
+					}
+					continue
+				}
+				bySignature[signature] = len(next)
+				next = append(next, candidate)
+			}
+		}
+		if len(next) == 0 {
+			// nothing printable fits, the messages probably aren't text here
+			for i, p := range beam {
+				next = append(next, partialKey{score: p.score, parent: i, endings: p.endings})
+			}
+		}
+		// sorting indexes is a lot faster than moving the partial keys around
+		order := make([]int, len(next))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool { return next[order[i]].score > next[order[j]].score })
+		if len(order) > beamWidth {
+			order = order[:beamWidth]
+		}
+		beam = make([]partialKey, len(order))
+		for i, j := range order {
+			beam[i] = next[j]
+		}
+		steps = append(steps, beam)
+	}
+
+	// follow the best partial key back to the start
+	for pos, i := len(steps)-1, 0; pos >= 0; pos-- {
+		p := steps[pos][i]
+		if p.known && !s.known[pos] {
+			s.key[pos], s.known[pos] = p.key, true
+		}
+		i = p.parent
+	}
+}
+
+// auto runs the automatic solver, keeping whatever is already known
+func (s *session) auto() {
+	s.decode()
+}
+
+func (s *session) show(w io.Writer) {
+	for i := range s.ciphertexts {
+		fmt.Fprintf(w, "  %d: %q\n", i, s.plaintext(i))
+	}
+	known := 0
+	for _, k := range s.known {
+		if k {
+			known++
+		}
+	}
+	fmt.Fprintf(w, "  key: %v of %v bytes known\n", known, len(s.key))
+}
+
+// parseText takes the rest of a command line, unquoting it if it's quoted
+func parseText(s string) []byte {
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		return []byte(unquoted)
+	}
+	return []byte(s)
+}
+
+// command runs one interactive command, and reports false when it's time to quit
+func (s *session) command(line string, out io.Writer) bool {
+	cmd, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	switch cmd {
+	case "drag":
+		crib := parseText(rest)
+		placements := s.drag(crib)
+		if len(placements) > 10 {
+			placements = placements[:10]
+		}
+		for _, p := range placements {
+			fmt.Fprintf(out, "  message %d offset %3d:", p.msg, p.offset)
+			for other, fragment := range p.revealed {
+				if fragment == nil {
+					continue
+				}
+				fmt.Fprintf(out, "  %d: %q", other, fragment)
+			}
+			fmt.Fprintf(out, "  (score %.2f)\n", p.score)
+		}
+	case "place":
+		fields := strings.SplitN(rest, " ", 3)
+		if len(fields) != 3 {
+			fmt.Fprintln(out, "  usage: place MESSAGE OFFSET TEXT")
+			break
+		}
+		msg, err1 := strconv.Atoi(fields[0])
+		offset, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil || msg < 0 || msg >= len(s.ciphertexts) {
+			fmt.Fprintln(out, "  usage: place MESSAGE OFFSET TEXT")
+			break
+		}
+		if _, err := s.place(msg, offset, parseText(fields[2])); err != nil {
+			fmt.Fprintln(out, " ", err)
+			break
+		}
+		s.show(out)
+	case "show":
+		s.show(out)
+	case "auto":
+		s.auto()
+		s.show(out)
+	case "quit", "exit":
+		return false
+	case "":
+	default:
+		fmt.Fprintln(out, "  commands: drag TEXT, place MESSAGE OFFSET TEXT, show, auto, quit")
+	}
+	return true
+}
+
+func (s *session) interactive(in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	fmt.Fprint(out, "> ")
+	for scanner.Scan() {
+		if !s.command(scanner.Text(), out) {
+			return
+		}
+		fmt.Fprint(out, "> ")
+	}
+}
+
+func crypt(plaintext, key []byte) []byte {
+	ciphertext := make([]byte, len(plaintext))
+	for i := range plaintext {
+		keyByte := key[i%len(key)]
+		ciphertext[i] = plaintext[i] ^ keyByte
+	}
+	return ciphertext
+}
+
+func readCorpus(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// countRight counts the characters of message i that match plaintexts[order[i]]
+func countRight(s *session, plaintexts []string, order []int) int {
+	right := 0
+	for i, o := range order {
+		recovered, p := s.plaintext(i), plaintexts[o]
+		for j := 0; j < len(p) && j < len(recovered); j++ {
+			if recovered[j] == p[j] {
+				right++
+			}
+		}
+	}
+	return right
+}
+
+// countEitherLine counts the characters of two messages that are right in either line,
+// so text that crossed over to the other message still counts
+func countEitherLine(s *session, plaintexts []string) int {
+	right := 0
+	for j := range s.key {
+		straight, swapped := 0, 0
+		for i := 0; i < 2; i++ {
+			recovered := s.plaintext(i)
+			if j < len(recovered) && j < len(plaintexts[i]) && recovered[j] == plaintexts[i][j] {
+				straight++
+			}
+			if j < len(recovered) && j < len(plaintexts[1-i]) && recovered[j] == plaintexts[1-i][j] {
+				swapped++
+			}
+		}
+		if swapped > straight {
+			straight = swapped
+		}
+		right += straight
+	}
+	return right
+}
+
+func test(plaintexts []string, key []byte, model *languageModel) {
+	ciphertexts := [][]byte{}
+	for _, p := range plaintexts {
+		ciphertexts = append(ciphertexts, crypt([]byte(p), key))
+	}
+	s, err := newSession(ciphertexts, model)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%v ciphertexts, XOR of the first two: %x\n", len(ciphertexts), crypt(ciphertexts[0], ciphertexts[1]))
+	s.auto()
+	s.show(os.Stdout)
+
+	// with only two messages p1 XOR p2 = p2 XOR p1, so nothing says which plaintext is which
+	order := []int{}
+	for i := range plaintexts {
+		order = append(order, i)
+	}
+	if len(plaintexts) == 2 && countRight(s, plaintexts, []int{1, 0}) > countRight(s, plaintexts, order) {
+		order = []int{1, 0}
+		fmt.Println("  the plaintexts came out swapped, two messages can't tell which is which")
+	}
+	total := 0
+	for _, p := range plaintexts {
+		total += len(p)
+	}
+	fmt.Printf("  %v of %v characters right\n", countRight(s, plaintexts, order), total)
+	if len(plaintexts) == 2 {
+		fmt.Printf("  %v of %v right in one line or the other, they cross where the messages match\n", countEitherLine(s, plaintexts), total)
+	}
+	fmt.Println("========")
+}
+
+func main() {
+	interactive := flag.Bool("i", false, "interactive crib dragging")
+	corpusPath := flag.String("corpus", "", "English text for the language model (default: the built-in corpus.txt)")
+	flag.Parse()
+
+	text := corpus
+	if *corpusPath != "" {
+		var err error
+		text, err = readCorpus(*corpusPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	model := newLanguageModel(text, modelOrder)
+
+	if flag.NArg() > 0 {
+		ciphertexts := [][]byte{}
+		for _, arg := range flag.Args() {
+			c, err := hex.DecodeString(arg)
+			if err != nil {
+				log.Fatal(err)
+			}
+			ciphertexts = append(ciphertexts, c)
+		}
+		s, err := newSession(ciphertexts, model)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *interactive {
+			s.interactive(os.Stdin, os.Stdout)
+			return
+		}
+		s.auto()
+		s.show(os.Stdout)
+		fmt.Printf("  key: %x\n", s.key)
+		return
+	}
+
+	messages := []string{
+		"Meet me at the north gate at midnight and bring the papers with you",
+		"The vendor has not paid the invoice and we will stop the service on Friday",
+		"Please send the new password to the whole team before the end of the day",
+	}
+	key := make([]byte, 80)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatal(err)
+	}
+	test(messages[:2], key, model)
+	test(messages, key, model)
+
+	fmt.Println("Interactive session on the first two messages:")
+	s, err := newSession([][]byte{crypt([]byte(messages[0]), key), crypt([]byte(messages[1]), key)}, model)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, line := range []string{`drag " the "`, "place 0 11 the north gate ", `drag " and "`, `place 0 37 " and bring"`, `place 0 47 " the papers"`, "auto"} {
+		fmt.Printf("> %v\n", line)
+		s.command(line, os.Stdout)
+	}
+}
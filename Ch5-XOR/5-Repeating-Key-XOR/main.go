@@ -0,0 +1,267 @@
+/*
+Repeating-Key XOR
+crypt XORs byte i of the message with key[i % len(key)]. With a key as long as the message that's a
+one-time pad. With a short key it repeats, and then it can be broken with nothing but the
+ciphertext. The Known Plaintext lesson needed a piece of the message, here we don't.
+
+1. Find the key size
+The Hamming distance of two byte strings is the number of bits that differ. Two blocks of English
+text are much closer to each other than two blocks of random bytes, because English uses a small
+set of byte values. If we cut the ciphertext into blocks of the right key size k, every block was
+XORed with the same key, and XORing two of them cancels the key out:
+
+(p1 XOR key) XOR (p2 XOR key) = p1 XOR p2
+
+So the distance between ciphertext blocks is the distance between plaintext blocks: small. With
+the wrong size the key doesn't line up and the distance looks random. Dividing by k makes sizes
+comparable, and averaging over many block pairs smooths out the noise.
+
+2. Transpose
+Byte 0, k, 2k, ... of the ciphertext were all XORed with key[0]. Put them in one column, bytes 1,
+k+1, 2k+1, ... in the next, and so on. Each column is a single-byte XOR.
+
+3. Solve each column
+Try all 256 key bytes on a column and keep the one whose output looks most like English text,
+scored with letter and space frequencies.
+
+The few best key sizes are all solved this way, and the one whose plaintext scores best wins. Like
+with Vigenère, multiples of the key size also work and give the key repeated, so the key is shortened
+to its smallest repeating unit.
+
+Usage
+go run . [-max 40] HEX
+Running with no arguments runs the demo.
+*/
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// log10 frequencies of bytes in English text, everything else gets unknownByteScore
+var byteScores [256]float64
+
+const unknownByteScore = -6
+
+func init() {
+	letters := "etaoinshrdlcumwfgypbvkjxqz"
+	frequencies := []float64{
+		0.1027, 0.0752, 0.0653, 0.0616, 0.0571, 0.0567, 0.0532, 0.0498, 0.0499, 0.0328, 0.0332, 0.0223,
+		0.0228, 0.0203, 0.0170, 0.0198, 0.0162, 0.0143, 0.0150, 0.0126, 0.0080, 0.0056, 0.0010, 0.0014,
+		0.0008, 0.0005,
+	}
+	for i := range byteScores {
+		byteScores[i] = unknownByteScore
+	}
+	for i, c := range []byte(letters) {
+		byteScores[c] = math.Log10(frequencies[i])
+		byteScores[c-'a'+'A'] = math.Log10(frequencies[i] / 10)
+	}
+	byteScores[' '] = math.Log10(0.18)
+	for _, c := range []byte(".,'\n") {
+		byteScores[c] = math.Log10(0.005)
+	}
+	for _, c := range []byte("0123456789!?-:;\"()") {
+		byteScores[c] = math.Log10(0.0005)
+	}
+}
+
+func hammingDistance(a, b []byte) (int, error) {
+	if len(a) != len(b) {
+		return 0, errors.New("inputs must be the same length")
+	}
+	d := 0
+	for i := range a {
+		d += bits.OnesCount8(a[i] ^ b[i])
+	}
+	return d, nil
+}
+
+type keySize struct {
+	size     int
+	distance float64 // average Hamming distance between blocks, per byte
+}
+
+// rankKeySizes scores every key size from 1 to maxSize, the most likely first
+func rankKeySizes(ciphertext []byte, maxSize int) []keySize {
+	sizes := []keySize{}
+	for size := 1; size <= maxSize; size++ {
+		blocks := len(ciphertext) / size
+		if blocks < 2 {
+			break
+		}
+		total, pairs := 0, 0
+		// compare every block with the next one
+		for i := 0; i+1 < blocks; i++ {
+			d, _ := hammingDistance(ciphertext[i*size:(i+1)*size], ciphertext[(i+1)*size:(i+2)*size])
+			total += d
+			pairs++
+		}
+		sizes = append(sizes, keySize{size: size, distance: float64(total) / float64(pairs) / float64(size)})
+	}
+	sort.SliceStable(sizes, func(i, j int) bool { return sizes[i].distance < sizes[j].distance })
+	return sizes
+}
+
+// transpose puts every size-th byte in the same column
+func transpose(ciphertext []byte, size int) [][]byte {
+	columns := make([][]byte, size)
+	for i, b := range ciphertext {
+		columns[i%size] = append(columns[i%size], b)
+	}
+	return columns
+}
+
+func score(text []byte) float64 {
+	total := 0.0
+	for _, b := range text {
+		total += byteScores[b]
+	}
+	return total
+}
+
+// solveSingleByte finds the key byte that turns column into the most English-looking text
+func solveSingleByte(column []byte) (byte, float64) {
+	best, bestScore := byte(0), math.Inf(-1)
+	decrypted := make([]byte, len(column))
+	for k := 0; k < 256; k++ {
+		for i, c := range column {
+			decrypted[i] = c ^ byte(k)
+		}
+		if s := score(decrypted); s > bestScore {
+			best, bestScore = byte(k), s
+		}
+	}
+	return best, bestScore
+}
+
+// shortestPeriod returns the smallest prefix of key that repeats to make all of key
+func shortestPeriod(key []byte) []byte {
+	for p := 1; p < len(key); p++ {
+		if len(key)%p != 0 {
+			continue
+		}
+		repeats := true
+		for i := p; i < len(key); i++ {
+			if key[i] != key[i-p] {
+				repeats = false
+				break
+			}
+		}
+		if repeats {
+			return key[:p]
+		}
+	}
+	return key
+}
+
+// breakRepeatingXOR solves the candidates best key sizes and returns the key whose plaintext scores best
+func breakRepeatingXOR(ciphertext []byte, maxSize, candidates int) ([]byte, []byte, error) {
+	sizes := rankKeySizes(ciphertext, maxSize)
+	if len(sizes) == 0 {
+		return nil, nil, errors.New("ciphertext is too short")
+	}
+	if len(sizes) > candidates {
+		sizes = sizes[:candidates]
+	}
+
+	var bestKey []byte
+	bestScore := math.Inf(-1)
+	for _, ks := range sizes {
+		key := make([]byte, ks.size)
+		for i, column := range transpose(ciphertext, ks.size) {
+			key[i], _ = solveSingleByte(column)
+		}
+		if s := score(crypt(ciphertext, key)); s > bestScore {
+			bestKey, bestScore = key, s
+		}
+	}
+	key := shortestPeriod(bestKey)
+	return key, crypt(ciphertext, key), nil
+}
+
+func crypt(plaintext, key []byte) []byte {
+	ciphertext := make([]byte, len(plaintext))
+	for i := range plaintext {
+		keyByte := key[i%len(key)]
+		ciphertext[i] = plaintext[i] ^ keyByte
+	}
+	return ciphertext
+}
+
+const message = `At Passly we practice cryptanalysis, meaning that we try to break our own encryption
+algorithms. We do this to make sure that our encryption is secure! Repeating a short key over a long
+message is one of the oldest mistakes there is. It turns a one-time pad into something much closer
+to a Vigenere cipher, and those have been broken since the nineteenth century. The attacker doesn't
+need to know a single word of the message, only that it is written in English. Once the key size is
+known, every column is a single-byte XOR, and there are only 256 of those to try.`
+
+func test(name string, key []byte) {
+	ciphertext := crypt([]byte(message), key)
+	fmt.Printf("%v (%v bytes): %x\n", name, len(key), key)
+	fmt.Print("  key sizes by distance:")
+	for _, ks := range rankKeySizes(ciphertext, 40)[:5] {
+		fmt.Printf(" %v (%.2f)", ks.size, ks.distance)
+	}
+	fmt.Println()
+
+	found, plaintext, err := breakRepeatingXOR(ciphertext, 40, 5)
+	if err != nil {
+		fmt.Println("  Error:", err)
+		fmt.Println("========")
+		return
+	}
+	result := "wrong"
+	if string(found) == string(key) {
+		result = "right"
+	}
+	fmt.Printf("  found key: %x (%v)\n", found, result)
+	first, _, _ := strings.Cut(string(plaintext), "\n")
+	fmt.Printf("  plaintext: %q...\n", first)
+	fmt.Println("========")
+}
+
+func main() {
+	maxSize := flag.Int("max", 40, "largest key size to try")
+	flag.Parse()
+
+	if flag.NArg() > 0 {
+		ciphertext, err := hex.DecodeString(strings.Join(flag.Args(), ""))
+		if err != nil {
+			log.Fatal(err)
+		}
+		key, plaintext, err := breakRepeatingXOR(ciphertext, *maxSize, 5)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Key: %x (%q)\n", key, key)
+		fmt.Println(string(plaintext))
+		return
+	}
+
+	a, b := []byte("this is a test"), []byte("wokka wokka!!!")
+	d, err := hammingDistance(a, b)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Hamming distance between %q and %q: %v\n", a, b, d)
+	fmt.Println("========")
+
+	test("Ch1 style key", []byte("PASSLY"))
+	test("Ch3 known plaintext key", []byte("thisIsMySecretKe"))
+	random := make([]byte, 29)
+	if _, err := rand.Read(random); err != nil {
+		log.Fatal(err)
+	}
+	test("Random key", random)
+}
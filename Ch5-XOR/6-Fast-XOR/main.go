@@ -0,0 +1,231 @@
+/*
+Fast XOR
+Every XOR helper so far works one byte at a time, and most of them append each byte to a new slice:
+
+func crypt(dat, key []byte) []byte {
+	final := []byte{}
+	for i, d := range dat {
+		final = append(final, d^key[i])
+	}
+	return final
+}
+
+That's fine for "Shazam", but the CPU can XOR 8 bytes in a single instruction, and append has to
+check the capacity on every byte and grow the slice again and again. For a file of a few megabytes
+the helper, not the cipher, becomes the slow part.
+
+Words instead of bytes
+xorBytes loads 8 bytes from each input as one uint64, XORs the words, and stores the result. Only the
+last len % 8 bytes are done one at a time. binary.LittleEndian is used to load and store the words,
+the compiler turns those calls into a single load or store. The output goes into a slice the caller
+owns, so nothing is allocated. dst may be the same slice as one of the inputs, that's the in-place
+variant: xorInPlace(buf, key) encrypts buf without a second buffer.
+
+Repeating keys
+crypt in this chapter XORs byte i with key[i % len(key)]. A word can't be loaded from the key when it
+wraps around the end, so repeatingKey copies the key out to 8 * len(key) bytes once. That length is
+both a multiple of 8 and of the key length, so every chunk of the message lines up with the start of
+the key again and can be XORed word by word. After newRepeatingKey, encrypting allocates nothing.
+
+Checking it
+The fast versions must give exactly the same bytes as the simple ones. The demo compares them on
+thousands of random inputs: random lengths, random keys, inputs that don't start on a word boundary,
+and in-place calls. Then it benchmarks them against the append and per-byte versions with
+testing.Benchmark. main_test.go does the same with go test: FuzzXorBytes and FuzzRepeatingKey
+compare them on inputs chosen by the fuzzer, and the benchmarks can be run with go test -bench .
+
+Every lesson is its own program, not a package another lesson can import, so the Stream Adapters
+lesson in Ch6 has a copy of xorBytes. Both lessons fuzz their copy, change the copy along with
+this one.
+
+Usage
+go run . [-checks 10000]
+go test -fuzz FuzzXorBytes
+go test -bench .
+Running with no arguments runs the demo.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"testing"
+)
+
+const wordSize = 8
+
+// xorBytes sets dst[i] = a[i] ^ b[i] for every i < n = min(len(a), len(b)) and returns n.
+// dst must be at least n bytes long. dst may be a or b, but must not partially overlap them.
+func xorBytes(dst, a, b []byte) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	if len(dst) < n {
+		panic("xorBytes: dst too short")
+	}
+	i := 0
+	for ; i+wordSize <= n; i += wordSize {
+		w := binary.LittleEndian.Uint64(a[i:]) ^ binary.LittleEndian.Uint64(b[i:])
+		binary.LittleEndian.PutUint64(dst[i:], w)
+	}
+	for ; i < n; i++ {
+		dst[i] = a[i] ^ b[i]
+	}
+	return n
+}
+
+// xorInPlace XORs src into dst and returns the number of bytes changed
+func xorInPlace(dst, src []byte) int {
+	return xorBytes(dst, dst, src)
+}
+
+// repeatingKey is a key copied out so that it can be XORed a word at a time
+type repeatingKey struct {
+	expanded []byte
+}
+
+func newRepeatingKey(key []byte) (repeatingKey, error) {
+	if len(key) == 0 {
+		return repeatingKey{}, errors.New("key is empty")
+	}
+	return repeatingKey{expanded: bytes.Repeat(key, wordSize)}, nil
+}
+
+// xor sets dst[i] = src[i] ^ key[i % len(key)]. dst must be at least as long as src,
+// and may be src itself.
+func (k repeatingKey) xor(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("repeatingKey.xor: dst too short")
+	}
+	for i := 0; i < len(src); i += len(k.expanded) {
+		xorBytes(dst[i:], src[i:], k.expanded)
+	}
+}
+
+// the versions used in the earlier lessons, for comparison
+
+// appendXOR is crypt from Ch3 and xor from the Feistel lesson
+func appendXOR(dat, key []byte) []byte {
+	final := []byte{}
+	for i, d := range dat {
+		final = append(final, d^key[i])
+	}
+	return final
+}
+
+// crypt is crypt from this chapter
+func crypt(plaintext, key []byte) []byte {
+	ciphertext := make([]byte, len(plaintext))
+	for i := range plaintext {
+		keyByte := key[i%len(key)]
+		ciphertext[i] = plaintext[i] ^ keyByte
+	}
+	return ciphertext
+}
+
+// check compares the fast versions with the simple ones on n random inputs
+func check(r *rand.Rand, n int) error {
+	for c := 0; c < n; c++ {
+		size := r.Intn(300)
+		// start part way into a bigger buffer, so words don't always start on an 8 byte boundary
+		offset := r.Intn(wordSize)
+		buf := make([]byte, offset+size)
+		r.Read(buf)
+		data := buf[offset:]
+		pad := make([]byte, size)
+		r.Read(pad)
+
+		want := appendXOR(data, pad)
+		got := make([]byte, size)
+		if count := xorBytes(got, data, pad); count != size || !bytes.Equal(got, want) {
+			return fmt.Errorf("xorBytes differs for %v bytes at offset %v", size, offset)
+		}
+		inPlace := append([]byte{}, data...)
+		xorInPlace(inPlace, pad)
+		if !bytes.Equal(inPlace, want) {
+			return fmt.Errorf("xorInPlace differs for %v bytes at offset %v", size, offset)
+		}
+
+		key := make([]byte, 1+r.Intn(40))
+		r.Read(key)
+		k, err := newRepeatingKey(key)
+		if err != nil {
+			return err
+		}
+		want = crypt(data, key)
+		got = make([]byte, size)
+		k.xor(got, data)
+		if !bytes.Equal(got, want) {
+			return fmt.Errorf("repeatingKey.xor differs for %v bytes with a %v byte key", size, len(key))
+		}
+		k.xor(data, data)
+		if !bytes.Equal(data, want) {
+			return fmt.Errorf("in-place repeatingKey.xor differs for %v bytes with a %v byte key", size, len(key))
+		}
+	}
+	return nil
+}
+
+func benchmark(name string, size int, f func(dat, key []byte)) {
+	dat := make([]byte, size)
+	key := make([]byte, size)
+	rand.Read(dat)
+	rand.Read(key)
+	res := testing.Benchmark(func(b *testing.B) {
+		b.SetBytes(int64(size))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			f(dat, key)
+		}
+	})
+	mbPerSec := float64(size) * float64(res.N) / res.T.Seconds() / 1e6
+	fmt.Printf("  %-22v %12v ns/op %10.1f MB/s %6v allocs/op\n", name, res.NsPerOp(), mbPerSec, res.AllocsPerOp())
+}
+
+func main() {
+	checks := flag.Int("checks", 10000, "number of random inputs to compare")
+	flag.Parse()
+
+	r := rand.New(rand.NewSource(1))
+	if err := check(r, *checks); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%v random inputs: fast and simple versions give the same bytes\n", *checks)
+	fmt.Println("========")
+
+	// repeating 16 byte key, like the Ch3 lessons
+	shortKey, err := newRepeatingKey([]byte("thisIsMySecretKe"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, size := range []int{64, 4096, 1 << 20} {
+		fmt.Printf("%v bytes:\n", size)
+		benchmark("append per byte", size, func(dat, key []byte) {
+			appendXOR(dat, key)
+		})
+		benchmark("crypt per byte", size, func(dat, key []byte) {
+			crypt(dat, key)
+		})
+		out := make([]byte, size)
+		benchmark("xorBytes", size, func(dat, key []byte) {
+			xorBytes(out, dat, key)
+		})
+		benchmark("xorInPlace", size, func(dat, key []byte) {
+			xorInPlace(dat, key)
+		})
+		benchmark("crypt 16 byte key", size, func(dat, key []byte) {
+			crypt(dat, key[:16])
+		})
+		benchmark("repeatingKey.xor", size, func(dat, key []byte) {
+			shortKey.xor(dat, dat)
+		})
+		fmt.Println("========")
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// FuzzXorBytes checks xorBytes and xorInPlace against appendXOR. offset starts the data part way
+// into a buffer, so the words don't always start on an 8 byte boundary.
+func FuzzXorBytes(f *testing.F) {
+	f.Add([]byte("Shazam"), []byte("Sk7p13"), uint8(0))
+	f.Add([]byte("a message longer than one word"), []byte("and a key of about the same length"), uint8(3))
+	f.Add([]byte{}, []byte{}, uint8(0))
+	f.Fuzz(func(t *testing.T, data, pad []byte, offset uint8) {
+		if len(pad) > len(data) {
+			pad = pad[:len(data)]
+		}
+		data = data[:len(pad)]
+		buf := make([]byte, int(offset%wordSize)+len(data))
+		dat := buf[offset%wordSize:]
+		copy(dat, data)
+
+		want := appendXOR(dat, pad)
+		got := make([]byte, len(dat))
+		if n := xorBytes(got, dat, pad); n != len(dat) {
+			t.Fatalf("xorBytes returned %v, want %v", n, len(dat))
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("xorBytes(%x, %x) = %x, want %x", dat, pad, got, want)
+		}
+		xorInPlace(dat, pad)
+		if !bytes.Equal(dat, want) {
+			t.Fatalf("xorInPlace gave %x, want %x", dat, want)
+		}
+	})
+}
+
+// FuzzRepeatingKey checks repeatingKey.xor against crypt, into a new slice and in place
+func FuzzRepeatingKey(f *testing.F) {
+	f.Add([]byte("I'm lovin it"), []byte("PASSLY"))
+	f.Add([]byte("a message a lot longer than the key it is encrypted with"), []byte("thisIsMySecretKe"))
+	f.Add([]byte("x"), []byte("a key longer than the message"))
+	f.Fuzz(func(t *testing.T, data, key []byte) {
+		k, err := newRepeatingKey(key)
+		if len(key) == 0 {
+			if err == nil {
+				t.Fatal("newRepeatingKey accepted an empty key")
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := crypt(data, key)
+		got := make([]byte, len(data))
+		k.xor(got, data)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("repeatingKey.xor(%x) with key %x = %x, want %x", data, key, got, want)
+		}
+		k.xor(data, data)
+		if !bytes.Equal(data, want) {
+			t.Fatalf("in-place repeatingKey.xor with key %x = %x, want %x", key, data, want)
+		}
+	})
+}
+
+func benchmarkXOR(b *testing.B, size int, f func(dat, key []byte)) {
+	dat := make([]byte, size)
+	key := make([]byte, size)
+	rand.Read(dat)
+	rand.Read(key)
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f(dat, key)
+	}
+}
+
+func BenchmarkAppendXOR(b *testing.B) {
+	benchmarkXOR(b, 4096, func(dat, key []byte) {
+		appendXOR(dat, key)
+	})
+}
+
+func BenchmarkCrypt(b *testing.B) {
+	benchmarkXOR(b, 4096, func(dat, key []byte) {
+		crypt(dat, key)
+	})
+}
+
+func BenchmarkXorBytes(b *testing.B) {
+	out := make([]byte, 4096)
+	benchmarkXOR(b, 4096, func(dat, key []byte) {
+		xorBytes(out, dat, key)
+	})
+}
+
+func BenchmarkXorInPlace(b *testing.B) {
+	benchmarkXOR(b, 4096, func(dat, key []byte) {
+		xorInPlace(dat, key)
+	})
+}
+
+func BenchmarkRepeatingKey(b *testing.B) {
+	k, err := newRepeatingKey([]byte("thisIsMySecretKe"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkXOR(b, 4096, func(dat, key []byte) {
+		k.xor(dat, dat)
+	})
+}
@@ -0,0 +1,466 @@
+/*
+Stream Adapters
+The crypt function from the first lessons sends every byte through three unbuffered channels:
+
+plaintextCh -> crypt <- keyCh
+                 |
+               result
+
+That shows nicely how a stream cipher works one byte at a time, but encrypt still needs the whole
+message and the whole key in memory as slices, and every byte costs three channel handoffs between
+goroutines. That's far slower than the XOR itself.
+
+cipher.Stream
+The standard library describes a stream cipher with a single method:
+
+type Stream interface {
+	XORKeyStream(dst, src []byte)
+}
+
+It XORs src with the next len(src) bytes of keystream and writes the result to dst. The stream keeps
+its own state, the position in the keystream, between calls, so a message can be fed to it in
+chunks of any size. AES in CTR mode, ChaCha20 and our one-time pad can all be a Stream.
+
+padStream reads the one-time pad key from an io.Reader, so the key can be a file too, and XORs it in
+with xorBytes from the Fast XOR lesson. channelStream is the same cipher, but every call goes
+through the original crypt function and its channels. It's kept as a teaching mode: the output is
+byte for byte the same, only the speed differs.
+
+XORKeyStream can't return an error. Like a cipher.Stream whose keystream is used up, both panic
+when the key runs out, and when reading the key fails. So check the key is at least as long as the
+message before starting, the way cryptFile does.
+
+Readers and writers
+With a Stream, encrypting a file or a network connection is a small wrapper:
+- streamReader reads from an io.Reader and decrypts what it read, in place.
+- streamWriter encrypts into a buffer it reuses and writes that to an io.Writer.
+Neither ever holds more than one buffer of the message, so a file of many gigabytes works with a
+few KB of memory. These do the same as cipher.StreamReader and cipher.StreamWriter, and any
+cipher.Stream works with either one.
+
+The benchmarks at the end of the demo compare the two designs. The channel pipeline manages less
+than a megabyte per second, every byte waits on three goroutines. padStream is about a thousand
+times faster on large messages. For tiny messages most of the time goes into allocating the
+writer's buffer, so reuse a writer when encrypting many small messages. main_test.go has the same
+benchmarks for go test -bench . and fuzz tests that check both streams and the xorBytes copy
+against a plain XOR.
+
+Usage
+go run . [-channels] -key KEYFILE INFILE OUTFILE
+The key file must be at least as long as the input. Running it again on the output decrypts it.
+go test -bench .
+Running with no arguments runs the demo.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+const (
+	wordSize   = 8
+	bufferSize = 32 * 1024
+)
+
+// xorBytes is a copy of xorBytes in Ch5-XOR/6-Fast-XOR. Keep the two the same, both lessons test it.
+// xorBytes sets dst[i] = a[i] ^ b[i] for every i < n = min(len(a), len(b)) and returns n.
+// dst must be at least n bytes long. dst may be a or b, but must not partially overlap them.
+func xorBytes(dst, a, b []byte) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	if len(dst) < n {
+		panic("xorBytes: dst too short")
+	}
+	i := 0
+	for ; i+wordSize <= n; i += wordSize {
+		w := binary.LittleEndian.Uint64(a[i:]) ^ binary.LittleEndian.Uint64(b[i:])
+		binary.LittleEndian.PutUint64(dst[i:], w)
+	}
+	for ; i < n; i++ {
+		dst[i] = a[i] ^ b[i]
+	}
+	return n
+}
+
+// padKey reads the next n bytes of a one-time pad into a buffer it reuses
+type padKey struct {
+	key io.Reader
+	buf []byte
+}
+
+// next panics when the key runs out, like any cipher.Stream that runs out of keystream.
+// It also panics when reading the key fails, XORKeyStream has no way to return the error.
+func (p *padKey) next(n int) []byte {
+	if cap(p.buf) < n {
+		p.buf = make([]byte, n)
+	}
+	p.buf = p.buf[:n]
+	if _, err := io.ReadFull(p.key, p.buf); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			panic("one-time pad: key is shorter than the message")
+		}
+		panic(fmt.Sprintf("one-time pad: reading the key: %v", err))
+	}
+	return p.buf
+}
+
+// padStream is a one-time pad as a cipher.Stream
+type padStream struct {
+	padKey
+}
+
+func newPadStream(key io.Reader) *padStream {
+	return &padStream{padKey{key: key}}
+}
+
+func (s *padStream) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("one-time pad: output smaller than input")
+	}
+	xorBytes(dst, src, s.next(len(src)))
+}
+
+// channelStream is the same one-time pad, but every byte goes through crypt and its channels
+type channelStream struct {
+	padKey
+}
+
+func newChannelStream(key io.Reader) *channelStream {
+	return &channelStream{padKey{key: key}}
+}
+
+func (s *channelStream) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("one-time pad: output smaller than input")
+	}
+	key := s.next(len(src))
+
+	textCh := make(chan byte)
+	keyCh := make(chan byte)
+	result := make(chan byte)
+
+	go func() {
+		defer close(textCh)
+		for _, v := range src {
+			textCh <- v
+		}
+	}()
+
+	go func() {
+		defer close(keyCh)
+		for _, v := range key {
+			keyCh <- v
+		}
+	}()
+
+	go crypt(textCh, keyCh, result)
+
+	i := 0
+	for v := range result {
+		dst[i] = v
+		i++
+	}
+}
+
+// crypt is the channel based crypt from the Stream Ciphers lesson
+func crypt(textCh, keyCh <-chan byte, result chan<- byte) {
+	defer close(result)
+
+	for {
+		textByte, ok1 := <-textCh
+		keyByte, ok2 := <-keyCh
+
+		if !ok1 || !ok2 {
+			return
+		}
+
+		result <- textByte ^ keyByte
+	}
+}
+
+// streamReader decrypts (or encrypts) everything read from r
+type streamReader struct {
+	s cipher.Stream
+	r io.Reader
+}
+
+func (sr streamReader) Read(p []byte) (int, error) {
+	n, err := sr.r.Read(p)
+	sr.s.XORKeyStream(p[:n], p[:n])
+	return n, err
+}
+
+// streamWriter encrypts (or decrypts) everything written to it before passing it on to w
+type streamWriter struct {
+	s   cipher.Stream
+	w   io.Writer
+	buf []byte
+}
+
+func newStreamWriter(s cipher.Stream, w io.Writer) *streamWriter {
+	return &streamWriter{s: s, w: w, buf: make([]byte, bufferSize)}
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := len(p)
+		if chunk > len(sw.buf) {
+			chunk = len(sw.buf)
+		}
+		sw.s.XORKeyStream(sw.buf[:chunk], p[:chunk])
+		n, err := sw.w.Write(sw.buf[:chunk])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if n != chunk {
+			return written, io.ErrShortWrite
+		}
+		p = p[chunk:]
+	}
+	return written, nil
+}
+
+// Close closes w if it can be closed
+func (sw *streamWriter) Close() error {
+	if c, ok := sw.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// encrypt is the slice based encrypt from the Stream Ciphers lesson
+func encrypt(plaintext, key []byte) ([]byte, error) {
+	if len(plaintext) != len(key) {
+		return nil, errors.New("plaintext and key must be the same length")
+	}
+
+	plaintextCh := make(chan byte)
+	keyCh := make(chan byte)
+	result := make(chan byte)
+
+	go func() {
+		defer close(plaintextCh)
+		for _, v := range plaintext {
+			plaintextCh <- v
+		}
+	}()
+
+	go func() {
+		defer close(keyCh)
+		for _, v := range key {
+			keyCh <- v
+		}
+	}()
+
+	go crypt(plaintextCh, keyCh, result)
+
+	res := []byte{}
+	for v := range result {
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+func cryptFile(inPath, outPath, keyPath string, channels bool) error {
+	in, err := os.Open(inPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	key, err := os.Open(keyPath)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+
+	inInfo, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	keyInfo, err := key.Stat()
+	if err != nil {
+		return err
+	}
+	if keyInfo.Size() < inInfo.Size() {
+		return fmt.Errorf("key is %v bytes, but the input is %v bytes", keyInfo.Size(), inInfo.Size())
+	}
+
+	var s cipher.Stream = newPadStream(key)
+	if channels {
+		s = newChannelStream(key)
+	}
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	w := newStreamWriter(s, out)
+	if _, err := io.Copy(w, in); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func testRoundTrip(name string, newStream func(key io.Reader) cipher.Stream, plaintext, key []byte) {
+	fmt.Printf("%v: encrypting %q\n", name, plaintext)
+	ciphertext := bytes.Buffer{}
+	w := newStreamWriter(newStream(bytes.NewReader(key)), &ciphertext)
+	// write in small pieces to show that the stream keeps its place between calls
+	for i := 0; i < len(plaintext); i += 5 {
+		end := i + 5
+		if end > len(plaintext) {
+			end = len(plaintext)
+		}
+		if _, err := w.Write(plaintext[i:end]); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
+	fmt.Printf("Encrypted ciphertext bytes: %v\n", ciphertext.Bytes())
+
+	r := streamReader{s: newStream(bytes.NewReader(key)), r: &ciphertext}
+	decrypted, err := io.ReadAll(r)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("Decrypted message: %v\n", string(decrypted))
+	fmt.Println("========")
+}
+
+func testFile(channels bool) error {
+	dir, err := os.MkdirTemp("", "stream-adapters")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	plaintext := bytes.Repeat([]byte("Streaming a file through the cipher, one buffer at a time.\n"), 20000)
+	key := make([]byte, len(plaintext))
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	paths := map[string][]byte{"plain.txt": plaintext, "key": key}
+	for name, data := range paths {
+		if err := os.WriteFile(dir+"/"+name, data, 0o600); err != nil {
+			return err
+		}
+	}
+	if err := cryptFile(dir+"/plain.txt", dir+"/cipher", dir+"/key", channels); err != nil {
+		return err
+	}
+	if err := cryptFile(dir+"/cipher", dir+"/decrypted.txt", dir+"/key", channels); err != nil {
+		return err
+	}
+	decrypted, err := os.ReadFile(dir + "/decrypted.txt")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Encrypted and decrypted a %v byte file, channels: %v, same as the original: %v\n",
+		len(plaintext), channels, bytes.Equal(decrypted, plaintext))
+	return nil
+}
+
+func benchmark(name string, size int, f func(plaintext, key []byte)) {
+	plaintext := make([]byte, size)
+	key := make([]byte, size)
+	rand.Read(plaintext)
+	rand.Read(key)
+	res := testing.Benchmark(func(b *testing.B) {
+		b.SetBytes(int64(size))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			f(plaintext, key)
+		}
+	})
+	mbPerSec := float64(size) * float64(res.N) / res.T.Seconds() / 1e6
+	fmt.Printf("  %-26v %12v ns/op %10.2f MB/s %8v allocs/op\n", name, res.NsPerOp(), mbPerSec, res.AllocsPerOp())
+}
+
+func main() {
+	keyPath := flag.String("key", "", "one-time pad key file")
+	channels := flag.Bool("channels", false, "use the channel based crypt, slow but the same output")
+	flag.Parse()
+
+	if flag.NArg() > 0 {
+		if flag.NArg() != 2 || *keyPath == "" {
+			log.Fatal("usage: go run . [-channels] -key KEYFILE INFILE OUTFILE")
+		}
+		if err := cryptFile(flag.Arg(0), flag.Arg(1), *keyPath, *channels); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	pad := func(key io.Reader) cipher.Stream { return newPadStream(key) }
+	chans := func(key io.Reader) cipher.Stream { return newChannelStream(key) }
+	testRoundTrip("padStream", pad, []byte("Shazam"), []byte("Sk7p13"))
+	testRoundTrip("channelStream", chans, []byte("Shazam"), []byte("Sk7p13"))
+	testRoundTrip("padStream", pad, []byte("I'm lovin it"), []byte("mysecurepass"))
+
+	// any cipher.Stream works with the adapters, and ours work with the standard library's
+	aesKey := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctr := bytes.Buffer{}
+	w := newStreamWriter(cipher.NewCTR(block, iv), &ctr)
+	if _, err := w.Write([]byte("AES-CTR through streamWriter")); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("AES-CTR ciphertext: %x\n", ctr.Bytes())
+	r := cipher.StreamReader{S: newPadStream(bytes.NewReader([]byte("Sk7p13"))), R: bytes.NewReader([]byte{0, 3, 86, 10, 80, 94})}
+	decrypted, err := io.ReadAll(r)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("padStream through cipher.StreamReader: %s\n", decrypted)
+	fmt.Println("========")
+
+	for _, channels := range []bool{false, true} {
+		if err := testFile(channels); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fmt.Println("========")
+
+	for _, size := range []int{64, 64 * 1024} {
+		fmt.Printf("%v bytes:\n", size)
+		benchmark("encrypt (channels)", size, func(plaintext, key []byte) {
+			encrypt(plaintext, key)
+		})
+		benchmark("streamWriter channelStream", size, func(plaintext, key []byte) {
+			w := newStreamWriter(newChannelStream(bytes.NewReader(key)), io.Discard)
+			w.Write(plaintext)
+		})
+		benchmark("streamWriter padStream", size, func(plaintext, key []byte) {
+			w := newStreamWriter(newPadStream(bytes.NewReader(key)), io.Discard)
+			w.Write(plaintext)
+		})
+		out := make([]byte, size)
+		s := newPadStream(nil)
+		benchmark("padStream reused", size, func(plaintext, key []byte) {
+			s.key = bytes.NewReader(key)
+			s.XORKeyStream(out, plaintext)
+		})
+		fmt.Println("========")
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// plainXOR is the byte at a time XOR the fast versions are checked against
+func plainXOR(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+// FuzzXorBytes checks the xorBytes copy against plainXOR. offset starts the data part way into a
+// buffer, so the words don't always start on an 8 byte boundary.
+func FuzzXorBytes(f *testing.F) {
+	f.Add([]byte("Shazam"), []byte("Sk7p13"), uint8(0))
+	f.Add([]byte("a message longer than one word"), []byte("and a key of about the same length"), uint8(3))
+	f.Add([]byte{}, []byte{}, uint8(0))
+	f.Fuzz(func(t *testing.T, data, pad []byte, offset uint8) {
+		if len(pad) > len(data) {
+			pad = pad[:len(data)]
+		}
+		data = data[:len(pad)]
+		buf := make([]byte, int(offset%wordSize)+len(data))
+		dat := buf[offset%wordSize:]
+		copy(dat, data)
+
+		want := plainXOR(dat, pad)
+		got := make([]byte, len(dat))
+		if n := xorBytes(got, dat, pad); n != len(dat) {
+			t.Fatalf("xorBytes returned %v, want %v", n, len(dat))
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("xorBytes(%x, %x) = %x, want %x", dat, pad, got, want)
+		}
+		xorBytes(dat, dat, pad)
+		if !bytes.Equal(dat, want) {
+			t.Fatalf("in-place xorBytes gave %x, want %x", dat, want)
+		}
+	})
+}
+
+// FuzzStreams feeds the message to padStream and channelStream in two pieces, split at split,
+// and checks both against plainXOR
+func FuzzStreams(f *testing.F) {
+	f.Add([]byte("I'm lovin it"), []byte("mysecurepass"), uint8(5))
+	f.Add([]byte("a message a lot longer than a word or two"), []byte("and a key that is even longer than the message"), uint8(0))
+	f.Add([]byte{}, []byte{}, uint8(0))
+	f.Fuzz(func(t *testing.T, data, key []byte, split uint8) {
+		if len(key) < len(data) {
+			data = data[:len(key)]
+		}
+		want := plainXOR(data, key)
+		cut := 0
+		if len(data) > 0 {
+			cut = int(split) % (len(data) + 1)
+		}
+		for _, s := range []struct {
+			name string
+			s    cipher.Stream
+		}{
+			{"padStream", newPadStream(bytes.NewReader(key))},
+			{"channelStream", newChannelStream(bytes.NewReader(key))},
+		} {
+			got := make([]byte, len(data))
+			s.s.XORKeyStream(got[:cut], data[:cut])
+			s.s.XORKeyStream(got[cut:], data[cut:])
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%v with key %x gave %x, want %x", s.name, key, got, want)
+			}
+		}
+	})
+}
+
+// panicMessage returns what f panicked with, or "" if it didn't panic
+func panicMessage(f func()) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprint(r)
+		}
+	}()
+	f()
+	return ""
+}
+
+// failingReader returns a few bytes of key, then err
+type failingReader struct {
+	n   int
+	err error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, r.err
+	}
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	r.n -= len(p)
+	return len(p), nil
+}
+
+func TestKeyRunsOut(t *testing.T) {
+	diskErr := errors.New("input/output error")
+	tests := []struct {
+		name string
+		key  io.Reader
+		want string
+	}{
+		{"no key left", &failingReader{0, io.EOF}, "key is shorter than the message"},
+		{"some key left", &failingReader{3, io.EOF}, "key is shorter than the message"},
+		{"read error", &failingReader{3, diskErr}, "reading the key: input/output error"},
+	}
+	for _, tt := range tests {
+		msg := panicMessage(func() {
+			newPadStream(tt.key).XORKeyStream(make([]byte, 6), []byte("Shazam"))
+		})
+		if !strings.Contains(msg, tt.want) {
+			t.Errorf("%v: panicked with %q, want %q", tt.name, msg, tt.want)
+		}
+	}
+}
+
+func benchmarkStream(b *testing.B, size int, f func(plaintext, key []byte)) {
+	plaintext := make([]byte, size)
+	key := make([]byte, size)
+	rand.Read(plaintext)
+	rand.Read(key)
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f(plaintext, key)
+	}
+}
+
+func BenchmarkEncrypt(b *testing.B) {
+	benchmarkStream(b, 64*1024, func(plaintext, key []byte) {
+		encrypt(plaintext, key)
+	})
+}
+
+func BenchmarkChannelStream(b *testing.B) {
+	benchmarkStream(b, 64*1024, func(plaintext, key []byte) {
+		w := newStreamWriter(newChannelStream(bytes.NewReader(key)), io.Discard)
+		w.Write(plaintext)
+	})
+}
+
+func BenchmarkPadStream(b *testing.B) {
+	benchmarkStream(b, 64*1024, func(plaintext, key []byte) {
+		w := newStreamWriter(newPadStream(bytes.NewReader(key)), io.Discard)
+		w.Write(plaintext)
+	})
+}
+
+func BenchmarkPadStreamSmall(b *testing.B) {
+	benchmarkStream(b, 64, func(plaintext, key []byte) {
+		w := newStreamWriter(newPadStream(bytes.NewReader(key)), io.Discard)
+		w.Write(plaintext)
+	})
+}
+
+func BenchmarkPadStreamReused(b *testing.B) {
+	s := newPadStream(nil)
+	out := make([]byte, 64*1024)
+	benchmarkStream(b, 64*1024, func(plaintext, key []byte) {
+		s.key = bytes.NewReader(key)
+		s.XORKeyStream(out, plaintext)
+	})
+}
@@ -0,0 +1,340 @@
+/*
+RC4
+Our encrypt insists that the key is exactly as long as the plaintext. That makes it a one-time pad
+fed through channels, not really a stream cipher. A real stream cipher takes a short key and
+stretches it into as much keystream as the message needs. RC4 was the most widely used one for
+about 20 years: WEP, WPA (TKIP), SSL and TLS all used it.
+
+The state is a permutation S of the 256 byte values and two indices i and j.
+
+Key scheduling (KSA)
+Start with S = 0, 1, 2, ..., 255 and shuffle it using the key:
+
+j = 0
+for i = 0..255:
+	j = j + S[i] + key[i % len(key)]
+	swap S[i], S[j]
+
+Keystream (PRGA)
+Each keystream byte moves i one step, moves j by S[i], swaps, and outputs one entry of S:
+
+i = i + 1
+j = j + S[i]
+swap S[i], S[j]
+output S[S[i] + S[j]]
+
+All the additions are mod 256, which is what uint8 does for free. The keystream goes into keyCh,
+and the crypt function from the first lesson XORs it with the message exactly like before.
+
+Biases
+RC4 is broken, and it's broken right at the start of the keystream. The KSA doesn't shuffle enough,
+so the first bytes still "remember" the key schedule:
+- The second byte is 0 twice as often as it should be (Mantin and Shamir, 2001). If the same message
+  is encrypted with many different keys, the most common second ciphertext byte is the second
+  plaintext byte.
+- The other early bytes have much smaller biases, e.g. byte r tends to be r, and with 16 byte keys
+  byte 16 tends to be 240 (256 - 16). Those are a fraction of a percent and take billions of keys to
+  measure, the million keys in the demo only show the second byte standing out.
+Attacks on WEP and on RC4 in TLS are built on biases like these. RC4-drop[n] throws away the first n
+bytes of keystream to skip the worst of them, n = 768 or 3072 are common. It helps, but biases remain
+further in, and RFC 7465 banned RC4 from TLS in 2015.
+
+The implementation is checked against the test vectors from RFC 6229, including the ones at
+offsets 240 and 496, which are also the first bytes of RC4-drop[240] and RC4-drop[496].
+
+Usage
+go run . [-drop 768] KEY encrypt TEXT
+go run . [-drop 768] KEY decrypt HEX
+Running with no arguments runs the demo.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+)
+
+type rc4 struct {
+	s    [256]uint8
+	i, j uint8
+}
+
+// newRC4 runs the key schedule and then throws away the first drop bytes of keystream
+func newRC4(key []byte, drop int) (*rc4, error) {
+	if len(key) < 1 || len(key) > 256 {
+		return nil, fmt.Errorf("key must be 1 to 256 bytes, got %v", len(key))
+	}
+	if drop < 0 {
+		return nil, errors.New("drop can't be negative")
+	}
+	c := &rc4{}
+	for i := range c.s {
+		c.s[i] = uint8(i)
+	}
+	j := uint8(0)
+	for i := range c.s {
+		j += c.s[i] + key[i%len(key)]
+		c.s[i], c.s[j] = c.s[j], c.s[i]
+	}
+	for n := 0; n < drop; n++ {
+		c.next()
+	}
+	return c, nil
+}
+
+// next returns the next keystream byte
+func (c *rc4) next() byte {
+	c.i++
+	c.j += c.s[c.i]
+	c.s[c.i], c.s[c.j] = c.s[c.j], c.s[c.i]
+	return c.s[c.s[c.i]+c.s[c.j]]
+}
+
+// keystream sends the next n keystream bytes and then closes the channel. It has to stop by
+// itself, crypt stops reading once the text runs out and a sender blocked forever would leak.
+func (c *rc4) keystream(n int) <-chan byte {
+	keyCh := make(chan byte)
+	go func() {
+		defer close(keyCh)
+		for k := 0; k < n; k++ {
+			keyCh <- c.next()
+		}
+	}()
+	return keyCh
+}
+
+func crypt(textCh, keyCh <-chan byte, result chan<- byte) {
+	defer close(result)
+
+	for {
+		textByte, ok1 := <-textCh
+		keyByte, ok2 := <-keyCh
+
+		if !ok1 || !ok2 {
+			return
+		}
+
+		result <- textByte ^ keyByte
+	}
+}
+
+// encrypt runs text through crypt with an RC4 keystream, decrypting is the same operation
+func encrypt(text, key []byte, drop int) ([]byte, error) {
+	c, err := newRC4(key, drop)
+	if err != nil {
+		return nil, err
+	}
+
+	textCh := make(chan byte)
+	result := make(chan byte)
+
+	go func() {
+		defer close(textCh)
+		for _, v := range text {
+			textCh <- v
+		}
+	}()
+
+	go crypt(textCh, c.keystream(len(text)), result)
+
+	res := []byte{}
+	for v := range result {
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+func decrypt(ciphertext, key []byte, drop int) ([]byte, error) {
+	return encrypt(ciphertext, key, drop)
+}
+
+// rfc6229 holds test vectors from RFC 6229: 32 bytes of keystream starting at offset
+var rfc6229 = []struct {
+	key       string
+	offset    int
+	keystream string
+}{
+	{"0102030405", 0, "b2396305f03dc027ccc3524a0a1118a86982944f18fc82d589c403a47a0d0919"},
+	{"0102030405", 240, "28cb1132c96ce286421dcaadb8b69eae1cfcf62b03eddb641d77dfcf7f8d8c93"},
+	{"0102030405", 496, "42b7d0cdd918a8a33dd51781c81f40416459844432a7da923cfb3eb4980661f6"},
+	{"01020304050607", 0, "293f02d47f37c9b633f2af5285feb46be620f1390d19bd84e2e0fd752031afc1"},
+	{"0102030405060708", 0, "97ab8a1bf0afb96132f2f67258da15a88263efdb45c4a18684ef87e6b19e5b09"},
+	{"0102030405060708090a0b0c0d0e0f10", 0, "9ac7cc9a609d1ef7b2932899cde41b975248c4959014126a6e8a84f11d1a9e1c"},
+	{"833222772a", 0, "80ad97bdc973df8a2e879e92a497efda20f060c2f2e5126501d3d4fea10d5fc0"},
+	{"ebb46227c6cc8b37641910833222772a", 0, "720c94b63edf44e131d950ca211a5a30c366fdeacf9ca80436be7c358424d20b"},
+}
+
+// testVectors encrypts zeros through the channel pipeline, which gives the keystream itself
+func testVectors() error {
+	for _, v := range rfc6229 {
+		key, err := hex.DecodeString(v.key)
+		if err != nil {
+			return err
+		}
+		want, err := hex.DecodeString(v.keystream)
+		if err != nil {
+			return err
+		}
+		got, err := encrypt(make([]byte, len(want)), key, v.offset)
+		if err != nil {
+			return err
+		}
+		result := "ok"
+		if !bytes.Equal(got, want) {
+			result = "FAILED"
+		}
+		fmt.Printf("RFC 6229 key %v, drop[%v]: %x %v\n", v.key, v.offset, got[:16], result)
+		if !bytes.Equal(got, want) {
+			return fmt.Errorf("keystream for key %v at offset %v is wrong", v.key, v.offset)
+		}
+	}
+	return nil
+}
+
+func test(plaintext, key []byte, drop int) {
+	fmt.Printf("Encrypting '%s' using key '%s', drop %v\n", plaintext, key, drop)
+	ciphertext, err := encrypt(plaintext, key, drop)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Encrypted ciphertext bytes: %v\n", ciphertext)
+	decrypted, err := decrypt(ciphertext, key, drop)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Decrypted message: %v\n", string(decrypted))
+	fmt.Println("========")
+}
+
+// countFirstBytes counts every value of the first n keystream bytes over many random 16 byte keys.
+// It calls next directly, the channels would make a million keys take minutes.
+func countFirstBytes(keys, n, drop int) ([][256]int, error) {
+	counts := make([][256]int, n)
+	key := make([]byte, 16)
+	for k := 0; k < keys; k++ {
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		c, err := newRC4(key, drop)
+		if err != nil {
+			return nil, err
+		}
+		for pos := range counts {
+			counts[pos][c.next()]++
+		}
+	}
+	return counts, nil
+}
+
+func testBiases(keys, drop int) error {
+	counts, err := countFirstBytes(keys, 16, drop)
+	if err != nil {
+		return err
+	}
+	expected := float64(keys) / 256
+	fmt.Printf("First 16 keystream bytes of %v random 16 byte keys, drop %v\n", keys, drop)
+	fmt.Println("probabilities relative to 1/256: 1.00 = no bias, random noise alone reaches about 1.05")
+	fmt.Println("byte   P(0)   most common value   its P")
+	for pos, c := range counts {
+		most := 0
+		for v := range c {
+			if c[v] > c[most] {
+				most = v
+			}
+		}
+		fmt.Printf("%4v   %4.2f   %17v   %5.2f\n", pos+1, float64(c[0])/expected, most, float64(c[most])/expected)
+	}
+	fmt.Println("========")
+	return nil
+}
+
+// testBroadcast encrypts the same secret under many keys and recovers its second byte from the
+// ciphertexts alone, using the bias of the second keystream byte towards 0
+func testBroadcast(secret []byte, keys, drop int) error {
+	counts := [256]int{}
+	key := make([]byte, 16)
+	for k := 0; k < keys; k++ {
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		c, err := newRC4(key, drop)
+		if err != nil {
+			return err
+		}
+		c.next()
+		counts[secret[1]^c.next()]++
+	}
+	guess := 0
+	for v := range counts {
+		if counts[v] > counts[guess] {
+			guess = v
+		}
+	}
+	fmt.Printf("%v encryptions of %q, drop %v: most common second ciphertext byte is %q, the secret's is %q\n",
+		keys, secret, drop, string(rune(guess)), string(secret[1]))
+	return nil
+}
+
+func main() {
+	drop := flag.Int("drop", 0, "keystream bytes to throw away, RC4-drop[n]")
+	flag.Parse()
+
+	if flag.NArg() > 0 {
+		if flag.NArg() < 3 {
+			log.Fatal("usage: go run . [-drop 768] KEY encrypt TEXT | KEY decrypt HEX")
+		}
+		key := []byte(flag.Arg(0))
+		text := strings.Join(flag.Args()[2:], " ")
+		switch flag.Arg(1) {
+		case "encrypt":
+			ciphertext, err := encrypt([]byte(text), key, *drop)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("%x\n", ciphertext)
+		case "decrypt":
+			ciphertext, err := hex.DecodeString(text)
+			if err != nil {
+				log.Fatal(err)
+			}
+			plaintext, err := decrypt(ciphertext, key, *drop)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(plaintext))
+		default:
+			log.Fatalf("unknown command %q, use encrypt or decrypt", flag.Arg(1))
+		}
+		return
+	}
+
+	if err := testVectors(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("========")
+
+	// the key no longer has to be as long as the message
+	test([]byte("Shazam"), []byte("Sk7p13"), 0)
+	test([]byte("I'm lovin it, and the key is much shorter than this message"), []byte("mysecurepass"), 0)
+	test([]byte("I'm lovin it"), []byte("mysecurepass"), 768)
+
+	for _, d := range []int{0, 768} {
+		if err := testBiases(1<<20, d); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, d := range []int{0, 768} {
+		if err := testBroadcast([]byte("Passly"), 1<<16, d); err != nil {
+			log.Fatal(err)
+		}
+	}
+}